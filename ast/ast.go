@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/vshalt/arbok/token"
@@ -11,6 +12,19 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
+}
+
+// nodeString renders a child node that may be missing from a partial tree
+// built during parser error recovery. Parse functions leave a missing
+// Expression or Statement child as a nil interface; children held as
+// *Identifier, *BlockStatement, *FunctionLiteral or *StringLiteral may be
+// nil pointers, whose String methods return "".
+func nodeString(node Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.String()
 }
 
 type Statement interface {
@@ -34,10 +48,17 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
-		out.WriteString(s.String())
+		out.WriteString(nodeString(s))
 	}
 	return out.String()
 }
@@ -48,19 +69,79 @@ type Identifier struct {
 }
 
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
 func (i *Identifier) expressionNode()      {}
-func (i *Identifier) String() string       { return i.Token.Literal }
+func (i *Identifier) String() string {
+	if i == nil {
+		return ""
+	}
+	return i.Token.Literal
+}
 
+// LetStatement binds Value to Name, or destructures it into Pattern when
+// the left-hand side is an array or hash pattern.
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Expression
+	Value   Expression
 }
 
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) String() string {
-	return fmt.Sprintf("%s %s = %s;", ls.TokenLiteral(), ls.Name.String(), ls.Value.String())
+	target := nodeString(ls.Name)
+	if ls.Pattern != nil {
+		target = nodeString(ls.Pattern)
+	}
+	return fmt.Sprintf("%s %s = %s;", ls.TokenLiteral(), target, nodeString(ls.Value))
+}
+
+// ArrayPattern destructures an array: each element is an identifier or a
+// nested pattern, and Rest collects the remaining elements.
+type ArrayPattern struct {
+	Token    token.Token
+	Elements []Expression
+	Rest     *Identifier
+}
+
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, nodeString(el))
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+}
+
+// HashPattern destructures a hash. Keys are identifiers or string literals
+// naming the looked-up keys; the value at the same index is the pattern the
+// entry is bound to, which is the key itself for the {name} shorthand.
+type HashPattern struct {
+	Token  token.Token
+	Keys   []Expression
+	Values []Expression
+}
+
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		if i < len(hp.Values) && hp.Values[i] != key {
+			pairs = append(pairs, nodeString(key)+": "+nodeString(hp.Values[i]))
+		} else {
+			pairs = append(pairs, nodeString(key))
+		}
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
 }
 
 type ReturnStatement struct {
@@ -69,9 +150,10 @@ type ReturnStatement struct {
 }
 
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) String() string {
-	return fmt.Sprintf("%s %s;", rs.TokenLiteral(), rs.ReturnValue.String())
+	return fmt.Sprintf("%s %s;", rs.TokenLiteral(), nodeString(rs.ReturnValue))
 }
 
 type ExpressionStatement struct {
@@ -80,8 +162,9 @@ type ExpressionStatement struct {
 }
 
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
 func (es *ExpressionStatement) statementNode()       {}
-func (es *ExpressionStatement) String() string       { return es.Expression.String() }
+func (es *ExpressionStatement) String() string       { return nodeString(es.Expression) }
 
 type IntegerLiteral struct {
 	Token token.Token
@@ -89,15 +172,27 @@ type IntegerLiteral struct {
 }
 
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type Boolean struct {
 	Token token.Token
 	Value bool
 }
 
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) String() string       { return b.Token.Literal }
 
@@ -108,9 +203,10 @@ type PrefixExpression struct {
 }
 
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) String() string {
-	return fmt.Sprintf("(%s%s)", pe.Operator, pe.Right.String())
+	return fmt.Sprintf("(%s%s)", pe.Operator, nodeString(pe.Right))
 }
 
 type InfixExpression struct {
@@ -121,9 +217,23 @@ type InfixExpression struct {
 }
 
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) String() string {
-	return fmt.Sprintf("(%s %s %s)", ie.Left.String(), ie.Operator, ie.Right.String())
+	return fmt.Sprintf("(%s %s %s)", nodeString(ie.Left), ie.Operator, nodeString(ie.Right))
+}
+
+type AssignExpression struct {
+	Token  token.Token
+	Target Expression
+	Value  Expression
+}
+
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Token.Pos }
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) String() string {
+	return fmt.Sprintf("(%s = %s)", nodeString(ae.Target), nodeString(ae.Value))
 }
 
 type IfExpression struct {
@@ -134,12 +244,133 @@ type IfExpression struct {
 }
 
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) String() string {
 	if ie.Alternative != nil {
-		return fmt.Sprintf("if %s %s else %s", ie.Condition.String(), ie.Consequence.String(), ie.Alternative.String())
+		return fmt.Sprintf("if %s %s else %s", nodeString(ie.Condition), nodeString(ie.Consequence), nodeString(ie.Alternative))
+	}
+	return fmt.Sprintf("if %s %s", nodeString(ie.Condition), nodeString(ie.Consequence))
+}
+
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) String() string {
+	return fmt.Sprintf("while %s %s", nodeString(ws.Condition), nodeString(ws.Body))
+}
+
+// ForStatement is a C-style loop; Init, Condition and Post are nil when the
+// corresponding clause is left empty.
+type ForStatement struct {
+	Token     token.Token
+	Init      Statement
+	Condition Expression
+	Post      Expression
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) String() string {
+	init := strings.TrimSuffix(nodeString(fs.Init), ";")
+	return fmt.Sprintf("for (%s; %s; %s) %s", init, nodeString(fs.Condition), nodeString(fs.Post), nodeString(fs.Body))
+}
+
+// ForInStatement walks over an iterable. Key is nil in the single-variable
+// form, where Value receives array elements, characters and hash keys.
+type ForInStatement struct {
+	Token    token.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) String() string {
+	variables := nodeString(fs.Value)
+	if fs.Key != nil {
+		variables = nodeString(fs.Key) + ", " + variables
 	}
-	return fmt.Sprintf("if %s %s", ie.Condition.String(), ie.Consequence.String())
+	return fmt.Sprintf("for (%s in %s) %s", variables, nodeString(fs.Iterable), nodeString(fs.Body))
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+type MatchExpression struct {
+	Token   token.Token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+// MatchArm is one "pattern if guard => body" arm of a match expression;
+// Guard is nil when the arm has none.
+type MatchArm struct {
+	Pattern Expression
+	Guard   Expression
+	Body    Expression
+}
+
+func (ma *MatchArm) String() string {
+	if ma.Guard != nil {
+		return fmt.Sprintf("%s if %s => %s", nodeString(ma.Pattern), nodeString(ma.Guard), nodeString(ma.Body))
+	}
+	return fmt.Sprintf("%s => %s", nodeString(ma.Pattern), nodeString(ma.Body))
+}
+
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	return fmt.Sprintf("match (%s) { %s }", nodeString(me.Subject), strings.Join(arms, ", "))
+}
+
+// TypePattern matches values whose object type is Type, such as INTEGER or
+// STRING, and optionally matches the value against Pattern as well.
+type TypePattern struct {
+	Token   token.Token
+	Type    string
+	Pattern Expression
+}
+
+func (tp *TypePattern) TokenLiteral() string { return tp.Token.Literal }
+func (tp *TypePattern) Pos() token.Position  { return tp.Token.Pos }
+func (tp *TypePattern) expressionNode()      {}
+func (tp *TypePattern) String() string {
+	if tp.Pattern != nil {
+		return fmt.Sprintf("%s(%s)", tp.Type, nodeString(tp.Pattern))
+	}
+	return tp.Type
 }
 
 type BlockStatement struct {
@@ -149,28 +380,117 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
+	if bs == nil {
+		return ""
+	}
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
-		out.WriteString(s.String())
+		out.WriteString(nodeString(s))
 	}
 	return out.String()
 }
 
+// FunctionLiteral holds the default values of its optional parameters in
+// Defaults, keyed by parameter name, and its rest parameter, if any, in Rest.
 type FunctionLiteral struct {
 	Token      token.Token
-	Parameters []*Identifier
+	Name       string
+	Parameters []Expression
+	Defaults   map[string]Expression
+	Rest       *Identifier
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) String() string {
+	if fl == nil {
+		return ""
+	}
+	params := ParameterStrings(fl.Parameters, fl.Defaults, fl.Rest)
+	return fmt.Sprintf("%s(%s) %s", fl.TokenLiteral(), strings.Join(params, ", "), nodeString(fl.Body))
+}
+
+// ParameterStrings renders a parameter list, including defaults and the
+// rest parameter, one string per parameter.
+func ParameterStrings(parameters []Expression, defaults map[string]Expression, rest *Identifier) []string {
+	params := []string{}
+	for _, param := range parameters {
+		if ident, ok := param.(*Identifier); ok && defaults[ident.Value] != nil {
+			params = append(params, param.String()+" = "+nodeString(defaults[ident.Value]))
+		} else {
+			params = append(params, nodeString(param))
+		}
+	}
+	if rest != nil {
+		params = append(params, "..."+rest.String())
+	}
+	return params
+}
+
+type FunctionStatement struct {
+	Token    token.Token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *FunctionStatement) statementNode()       {}
+func (fs *FunctionStatement) String() string {
+	literal := nodeString(fs.Function)
+	return fs.TokenLiteral() + " " + nodeString(fs.Name) + strings.TrimPrefix(literal, fs.TokenLiteral())
+}
+
+// MacroLiteral is macro(params) { body }. Macros are bound by top-level
+// let statements and expanded before the program is evaluated.
+type MacroLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos }
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) String() string {
 	params := []string{}
-	for _, param := range fl.Parameters {
+	for _, param := range ml.Parameters {
 		params = append(params, param.String())
 	}
-	return fmt.Sprintf("%s(%s) %s", fl.TokenLiteral(), strings.Join(params, ", "), fl.Body.String())
+	return fmt.Sprintf("%s(%s) %s", ml.TokenLiteral(), strings.Join(params, ", "), nodeString(ml.Body))
+}
+
+// ImportStatement is import "path" as name, which binds the module loaded
+// from Path to Name.
+type ImportStatement struct {
+	Token token.Token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) String() string {
+	return fmt.Sprintf("%s %s as %s;", is.TokenLiteral(), nodeString(is.Path), nodeString(is.Name))
+}
+
+// ExportStatement marks the bindings of a top-level let or function
+// statement as visible to modules that import this one.
+type ExportStatement struct {
+	Token     token.Token
+	Statement Statement
+}
+
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + nodeString(es.Statement)
 }
 
 type CallExpression struct {
@@ -181,12 +501,13 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Pos }
 func (ce *CallExpression) String() string {
 	args := []string{}
 	for _, arg := range ce.Arguments {
-		args = append(args, arg.String())
+		args = append(args, nodeString(arg))
 	}
-	return fmt.Sprintf("%s(%s)", ce.Function, strings.Join(args, ", "))
+	return fmt.Sprintf("%s(%s)", nodeString(ce.Function), strings.Join(args, ", "))
 }
 
 type StringLiteral struct {
@@ -194,9 +515,44 @@ type StringLiteral struct {
 	Value string
 }
 
-func (sl *StringLiteral) expressionNode()      {}
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) String() string {
+	if sl == nil {
+		return ""
+	}
+	return quoteString(sl.Value)
+}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+
+// quoteString escapes s so that it lexes back to the same value, including
+// a literal "${" that would otherwise start an interpolation.
+func quoteString(s string) string {
+	return strings.ReplaceAll(strconv.Quote(s), "${", `\${`)
+}
+
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString(`"`)
+	for _, part := range is.Parts {
+		if literal, ok := part.(*StringLiteral); ok {
+			quoted := literal.String()
+			out.WriteString(quoted[1 : len(quoted)-1])
+		} else {
+			out.WriteString("${" + nodeString(part) + "}")
+		}
+	}
+	out.WriteString(`"`)
+	return out.String()
+}
 
 type ArrayLiteral struct {
 	Token    token.Token
@@ -205,24 +561,57 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	elements := []string{}
 	for _, el := range al.Elements {
-		elements = append(elements, el.String())
+		elements = append(elements, nodeString(el))
 	}
 	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
 }
 
+// IndexExpression is left[index], or left?.[index] when Optional is set,
+// which yields null instead of failing when left is null.
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token
+	Left     Expression
+	Index    Expression
+	Optional bool
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IndexExpression) String() string {
-	return fmt.Sprintf("(%s[%s])", ie.Left.String(), ie.Index.String())
+	if ie.Optional {
+		return fmt.Sprintf("(%s?.[%s])", nodeString(ie.Left), nodeString(ie.Index))
+	}
+	return fmt.Sprintf("(%s[%s])", nodeString(ie.Left), nodeString(ie.Index))
+}
+
+// SliceExpression is left[start:end:step]; any of the bounds may be nil
+// when left out.
+type SliceExpression struct {
+	Token    token.Token
+	Left     Expression
+	Start    Expression
+	End      Expression
+	Step     Expression
+	Optional bool
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SliceExpression) String() string {
+	bounds := nodeString(se.Start) + ":" + nodeString(se.End)
+	if se.Step != nil {
+		bounds += ":" + nodeString(se.Step)
+	}
+	if se.Optional {
+		return fmt.Sprintf("(%s?.[%s])", nodeString(se.Left), bounds)
+	}
+	return fmt.Sprintf("(%s[%s])", nodeString(se.Left), bounds)
 }
 
 type HashLiteral struct {
@@ -232,10 +621,11 @@ type HashLiteral struct {
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	pairs := []string{}
 	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+		pairs = append(pairs, nodeString(key)+":"+nodeString(value))
 	}
 	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
 }
@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/vshalt/arbok/token"
@@ -20,3 +21,91 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() is wrong, got=%q", program.String())
 	}
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression {
+		return &IntegerLiteral{Token: token.Token{Type: token.INTEGER, Literal: "1"}, Value: 1}
+	}
+	two := func() Expression {
+		return &IntegerLiteral{Token: token.Token{Type: token.INTEGER, Literal: "2"}, Value: 2}
+	}
+	block := func(expr Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: expr}}}
+	}
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		{&IndexExpression{Left: one(), Index: one()}, &IndexExpression{Left: two(), Index: two()}},
+		{&SliceExpression{Left: one(), End: one()}, &SliceExpression{Left: two(), End: two()}},
+		{
+			&IfExpression{Condition: one(), Consequence: block(one()), Alternative: block(one())},
+			&IfExpression{Condition: two(), Consequence: block(two()), Alternative: block(two())},
+		},
+		{&IfExpression{Condition: one(), Consequence: block(one())}, &IfExpression{Condition: two(), Consequence: block(two())}},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{&LetStatement{Value: one()}, &LetStatement{Value: two()}},
+		{&AssignExpression{Target: one(), Value: one()}, &AssignExpression{Target: two(), Value: two()}},
+		{&WhileStatement{Condition: one(), Body: block(one())}, &WhileStatement{Condition: two(), Body: block(two())}},
+		{
+			&ForStatement{Init: &LetStatement{Value: one()}, Post: one(), Body: block(one())},
+			&ForStatement{Init: &LetStatement{Value: two()}, Post: two(), Body: block(two())},
+		},
+		{&ForInStatement{Iterable: one(), Body: block(one())}, &ForInStatement{Iterable: two(), Body: block(two())}},
+		{
+			&FunctionLiteral{Parameters: []Expression{}, Defaults: map[string]Expression{"a": one()}, Body: block(one())},
+			&FunctionLiteral{Parameters: []Expression{}, Defaults: map[string]Expression{"a": two()}, Body: block(two())},
+		},
+		{
+			&FunctionStatement{Function: &FunctionLiteral{Body: block(one())}},
+			&FunctionStatement{Function: &FunctionLiteral{Body: block(two())}},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one(), two()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two(), two()}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{&InterpolatedString{Parts: []Expression{one()}}, &InterpolatedString{Parts: []Expression{two()}}},
+		{
+			&MatchExpression{Subject: one(), Arms: []*MatchArm{{Pattern: one(), Guard: one(), Body: one()}}},
+			&MatchExpression{Subject: two(), Arms: []*MatchArm{{Pattern: one(), Guard: two(), Body: two()}}},
+		},
+	}
+	for _, tt := range tests {
+		before := tt.input.String()
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+		if tt.input.String() != before {
+			t.Errorf("input was modified in place. before=%q, after=%q", before, tt.input.String())
+		}
+	}
+
+	hash := &HashLiteral{Pairs: map[Expression]Expression{one(): one(), one(): one()}}
+	modified := Modify(hash, turnOneIntoTwo).(*HashLiteral)
+	if len(modified.Pairs) != 2 {
+		t.Fatalf("wrong number of pairs. got=%d", len(modified.Pairs))
+	}
+	for key, value := range modified.Pairs {
+		if key.(*IntegerLiteral).Value != 2 || value.(*IntegerLiteral).Value != 2 {
+			t.Errorf("pair was not modified. got=%s:%s", key, value)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// Modify walks the tree rooted at node depth first and returns a copy of it
+// in which every node has been replaced by the result of calling modifier on
+// it, children before parents. The original tree is left unchanged, so a
+// function body can be quoted any number of times. Patterns, parameter
+// names and macro literals are not visited.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		copied := *n
+		copied.Statements = modifyStatements(n.Statements, modifier)
+		node = &copied
+	case *ExpressionStatement:
+		copied := *n
+		copied.Expression = modifyExpression(n.Expression, modifier)
+		node = &copied
+	case *LetStatement:
+		copied := *n
+		copied.Value = modifyExpression(n.Value, modifier)
+		node = &copied
+	case *ReturnStatement:
+		copied := *n
+		copied.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+		node = &copied
+	case *BlockStatement:
+		copied := *n
+		copied.Statements = modifyStatements(n.Statements, modifier)
+		node = &copied
+	case *WhileStatement:
+		copied := *n
+		copied.Condition = modifyExpression(n.Condition, modifier)
+		copied.Body = modifyBlock(n.Body, modifier)
+		node = &copied
+	case *ForStatement:
+		copied := *n
+		copied.Init = modifyStatement(n.Init, modifier)
+		copied.Condition = modifyExpression(n.Condition, modifier)
+		copied.Post = modifyExpression(n.Post, modifier)
+		copied.Body = modifyBlock(n.Body, modifier)
+		node = &copied
+	case *ForInStatement:
+		copied := *n
+		copied.Iterable = modifyExpression(n.Iterable, modifier)
+		copied.Body = modifyBlock(n.Body, modifier)
+		node = &copied
+	case *ExportStatement:
+		copied := *n
+		copied.Statement = modifyStatement(n.Statement, modifier)
+		node = &copied
+	case *FunctionStatement:
+		copied := *n
+		if n.Function != nil {
+			copied.Function, _ = Modify(n.Function, modifier).(*FunctionLiteral)
+		}
+		node = &copied
+	case *PrefixExpression:
+		copied := *n
+		copied.Right = modifyExpression(n.Right, modifier)
+		node = &copied
+	case *InfixExpression:
+		copied := *n
+		copied.Left = modifyExpression(n.Left, modifier)
+		copied.Right = modifyExpression(n.Right, modifier)
+		node = &copied
+	case *AssignExpression:
+		copied := *n
+		copied.Target = modifyExpression(n.Target, modifier)
+		copied.Value = modifyExpression(n.Value, modifier)
+		node = &copied
+	case *IfExpression:
+		copied := *n
+		copied.Condition = modifyExpression(n.Condition, modifier)
+		copied.Consequence = modifyBlock(n.Consequence, modifier)
+		copied.Alternative = modifyBlock(n.Alternative, modifier)
+		node = &copied
+	case *MatchExpression:
+		copied := *n
+		copied.Subject = modifyExpression(n.Subject, modifier)
+		copied.Arms = make([]*MatchArm, len(n.Arms))
+		for i, arm := range n.Arms {
+			copied.Arms[i] = &MatchArm{
+				Pattern: arm.Pattern,
+				Guard:   modifyExpression(arm.Guard, modifier),
+				Body:    modifyExpression(arm.Body, modifier),
+			}
+		}
+		node = &copied
+	case *IndexExpression:
+		copied := *n
+		copied.Left = modifyExpression(n.Left, modifier)
+		copied.Index = modifyExpression(n.Index, modifier)
+		node = &copied
+	case *SliceExpression:
+		copied := *n
+		copied.Left = modifyExpression(n.Left, modifier)
+		copied.Start = modifyExpression(n.Start, modifier)
+		copied.End = modifyExpression(n.End, modifier)
+		copied.Step = modifyExpression(n.Step, modifier)
+		node = &copied
+	case *FunctionLiteral:
+		copied := *n
+		if n.Defaults != nil {
+			copied.Defaults = make(map[string]Expression, len(n.Defaults))
+			for name, value := range n.Defaults {
+				copied.Defaults[name] = modifyExpression(value, modifier)
+			}
+		}
+		copied.Body = modifyBlock(n.Body, modifier)
+		node = &copied
+	case *CallExpression:
+		copied := *n
+		copied.Function = modifyExpression(n.Function, modifier)
+		copied.Arguments = modifyExpressions(n.Arguments, modifier)
+		node = &copied
+	case *ArrayLiteral:
+		copied := *n
+		copied.Elements = modifyExpressions(n.Elements, modifier)
+		node = &copied
+	case *HashLiteral:
+		copied := *n
+		copied.Pairs = make(map[Expression]Expression, len(n.Pairs))
+		for key, value := range n.Pairs {
+			copied.Pairs[modifyExpression(key, modifier)] = modifyExpression(value, modifier)
+		}
+		node = &copied
+	case *InterpolatedString:
+		copied := *n
+		copied.Parts = modifyExpressions(n.Parts, modifier)
+		node = &copied
+	}
+	return modifier(node)
+}
+
+// modifyExpression modifies an optional child expression. A replacement
+// that is not an expression leaves the child nil.
+func modifyExpression(expr Expression, modifier ModifierFunc) Expression {
+	if expr == nil {
+		return nil
+	}
+	modified, _ := Modify(expr, modifier).(Expression)
+	return modified
+}
+
+func modifyExpressions(exprs []Expression, modifier ModifierFunc) []Expression {
+	if exprs == nil {
+		return nil
+	}
+	modified := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		modified[i] = modifyExpression(expr, modifier)
+	}
+	return modified
+}
+
+func modifyStatement(statement Statement, modifier ModifierFunc) Statement {
+	if statement == nil {
+		return nil
+	}
+	modified, _ := Modify(statement, modifier).(Statement)
+	return modified
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) []Statement {
+	if statements == nil {
+		return nil
+	}
+	modified := make([]Statement, len(statements))
+	for i, statement := range statements {
+		modified[i] = modifyStatement(statement, modifier)
+	}
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	modified, _ := Modify(block, modifier).(*BlockStatement)
+	return modified
+}
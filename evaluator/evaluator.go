@@ -2,18 +2,31 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/vshalt/arbok/ast"
 	"github.com/vshalt/arbok/object"
+	"github.com/vshalt/arbok/token"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.ArrayLiteral:
 		expressions := evalExpression(node.Elements, env)
@@ -21,11 +34,26 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return expressions[0]
 		}
 		return &object.Array{Elements: expressions}
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.CallExpression:
+		if isSpecialForm(node, "quote") {
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments to `quote`. got=%d, expected=1", len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env)
+		}
+		if isSpecialForm(node, "unquote") {
+			return newError("unquote called outside of quote")
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -40,7 +68,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Name: node.Name, Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Body: body, Env: env}
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.MacroLiteral:
+		return newError("macros can only be defined by top-level let statements")
+	case *ast.FunctionStatement:
+		env.Set(node.Name.Value, Eval(node.Function, env))
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	case *ast.Identifier:
@@ -52,14 +88,27 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 		index := Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return evalIndexExpression(left, index, env.Strict())
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+		if node.Operator == "??" {
+			return evalNullishExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -74,6 +123,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+			return nil
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
@@ -91,32 +146,240 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.ReturnValue{Value: val}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
-
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
 	}
 	return nil
 }
 
-func evalIndexExpression(left object.Object, index object.Object) object.Object {
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if !env.Assign(target.Value, val) {
+			return newError("cannot assign to undeclared identifier: %s", target.Value)
+		}
+		return val
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return evalIndexAssignment(left, index, val)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+func evalIndexAssignment(left object.Object, index object.Object, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		position := idx.Value
+		if position < 0 {
+			position += int64(len(left.Elements))
+		}
+		if position < 0 || position >= int64(len(left.Elements)) {
+			return newError("array index out of range: %d", idx.Value)
+		}
+		left.Elements[position] = val
+		return val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+		return val
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func evalIndexExpression(left object.Object, index object.Object, strict bool) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+		return evalArrayIndexExpression(left, index, strict)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index, strict)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return evalModuleMember(left.(*object.Module), index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
-func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
+// evalArrayIndexExpression counts negative indexes from the end of the
+// array; indexes outside it give null, or an error in strict mode.
+func evalArrayIndexExpression(array object.Object, index object.Object, strict bool) object.Object {
 	arrayObj := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObj.Elements) - 1)
-	if idx < 0 || idx > max {
+	length := int64(len(arrayObj.Elements))
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		if strict {
+			return newError("array index out of range: %d", index.(*object.Integer).Value)
+		}
 		return NULL
 	}
 	return arrayObj.Elements[idx]
 }
 
+// evalStringIndexExpression returns the code point at the index as a
+// one-character string, with the same bounds rules as arrays.
+func evalStringIndexExpression(str object.Object, index object.Object, strict bool) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	length := int64(len(runes))
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		if strict {
+			return newError("string index out of range: %d", index.(*object.Integer).Value)
+		}
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if node.Optional && left == NULL {
+		return NULL
+	}
+	bounds := []object.Object{}
+	for _, bound := range []ast.Expression{node.Start, node.End, node.Step} {
+		if bound == nil {
+			bounds = append(bounds, nil)
+			continue
+		}
+		value := Eval(bound, env)
+		if isError(value) {
+			return value
+		}
+		if value.Type() != object.INTEGER_OBJ {
+			return newError("slice index must be INTEGER, got %s", value.Type())
+		}
+		bounds = append(bounds, value)
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		indexes, err := sliceIndexes(int64(len(left.Elements)), bounds[0], bounds[1], bounds[2], env.Strict())
+		if err != nil {
+			return err
+		}
+		elements := make([]object.Object, 0, len(indexes))
+		for _, i := range indexes {
+			elements = append(elements, left.Elements[i])
+		}
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		indexes, err := sliceIndexes(int64(len(runes)), bounds[0], bounds[1], bounds[2], env.Strict())
+		if err != nil {
+			return err
+		}
+		sliced := make([]rune, 0, len(indexes))
+		for _, i := range indexes {
+			sliced = append(sliced, runes[i])
+		}
+		return &object.String{Value: string(sliced)}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceIndexes resolves the bounds of a slice over length elements into the
+// positions it selects. Negative bounds count from the end, omitted bounds
+// default to the whole sequence in the direction of step, and bounds past
+// either end are clamped, or are an error in strict mode.
+func sliceIndexes(length int64, start, end, step object.Object, strict bool) ([]int64, *object.Error) {
+	stepValue := int64(1)
+	if step != nil {
+		stepValue = step.(*object.Integer).Value
+	}
+	if stepValue == 0 {
+		return nil, newError("slice step cannot be zero")
+	}
+	lower, upper := int64(0), length
+	if stepValue < 0 {
+		lower, upper = -1, length-1
+	}
+	resolve := func(bound object.Object, fallback int64) (int64, *object.Error) {
+		if bound == nil {
+			return fallback, nil
+		}
+		value := bound.(*object.Integer).Value
+		if value < 0 {
+			value += length
+		}
+		if strict && (value < 0 || value > length) {
+			return 0, newError("slice index out of range: %d with length %d", bound.(*object.Integer).Value, length)
+		}
+		if value < lower {
+			value = lower
+		}
+		if value > upper {
+			value = upper
+		}
+		return value, nil
+	}
+
+	startDefault, endDefault := lower, upper
+	if stepValue < 0 {
+		startDefault, endDefault = upper, lower
+	}
+	startValue, err := resolve(start, startDefault)
+	if err != nil {
+		return nil, err
+	}
+	endValue, err := resolve(end, endDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := []int64{}
+	for i := startValue; stepValue > 0 && i < endValue || stepValue < 0 && i > endValue; i += stepValue {
+		indexes = append(indexes, i)
+		// Stop before a step past the end can overflow i.
+		if stepValue > 0 && stepValue >= endValue-i || stepValue < 0 && stepValue <= endValue-i {
+			break
+		}
+	}
+	return indexes, nil
+}
+
 func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
@@ -133,7 +396,10 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
@@ -143,18 +409,124 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the call arguments to the parameters of fn.
+// Missing optional parameters get their default values, which are evaluated
+// in the new environment so they can refer to earlier parameters, and extra
+// arguments are collected into the rest parameter.
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	if len(args) < required || len(args) > len(fn.Parameters) && fn.Rest == nil {
+		return nil, arityError(fn, len(args))
+	}
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramId, param := range fn.Parameters {
-		env.Set(param.Value, args[paramId])
+		if paramId < len(args) {
+			if err := bindPattern(param, args[paramId], env); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// Only identifiers can have defaults, and every parameter after the
+		// required ones has one.
+		name := param.(*ast.Identifier).Value
+		value := Eval(fn.Defaults[name], env)
+		if isError(value) {
+			return nil, value.(*object.Error)
+		}
+		env.Set(name, value)
+	}
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+// bindPattern binds value to the names in a destructuring pattern. Missing
+// array elements and hash keys bind to null, or are errors in strict mode.
+func bindPattern(pattern ast.Expression, value object.Object, env *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		env.Set(pattern.Value, value)
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return newErrorAt(pattern.Pos(), "cannot destructure %s as ARRAY", value.Type())
+		}
+		for i, element := range pattern.Elements {
+			var item object.Object = NULL
+			if i < len(array.Elements) {
+				item = array.Elements[i]
+			} else if env.Strict() {
+				return newErrorAt(element.Pos(), "missing array element %d in destructuring of %s", i, pattern)
+			}
+			if err := bindPattern(element, item, env); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			rest := []object.Object{}
+			if len(array.Elements) > len(pattern.Elements) {
+				rest = append(rest, array.Elements[len(pattern.Elements):]...)
+			}
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return newErrorAt(pattern.Pos(), "cannot destructure %s as HASH", value.Type())
+		}
+		for i, key := range pattern.Keys {
+			name := patternKey(key)
+			var item object.Object = NULL
+			if pair, ok := hash.Pairs[name.HashKey()]; ok {
+				item = pair.Value
+			} else if env.Strict() {
+				return newErrorAt(key.Pos(), "missing hash key %q in destructuring of %s", name.Value, pattern)
+			}
+			if err := bindPattern(pattern.Values[i], item, env); err != nil {
+				return err
+			}
+		}
+	default:
+		return newErrorAt(pattern.Pos(), "invalid destructuring pattern: %s", pattern)
 	}
-	return env
+	return nil
 }
 
+func patternKey(key ast.Expression) *object.String {
+	if literal, ok := key.(*ast.StringLiteral); ok {
+		return &object.String{Value: literal.Value}
+	}
+	return &object.String{Value: key.(*ast.Identifier).Value}
+}
+
+func arityError(fn *object.Function, got int) *object.Error {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	expected := fmt.Sprintf("%d", required)
+	if fn.Rest != nil {
+		expected = fmt.Sprintf("at least %d", required)
+	} else if required != len(fn.Parameters) {
+		expected = fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+	}
+	if fn.Name != "" {
+		return newError("wrong number of arguments to `%s`. got=%d, expected=%s", fn.Name, got, expected)
+	}
+	return newError("wrong number of arguments. got=%d, expected=%s", got, expected)
+}
+
+// unwrapReturnValue gives the value a function body produced; a body that
+// produces nothing, such as one ending in a loop, gives null.
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
 	}
+	if obj == nil {
+		return NULL
+	}
 	return obj
 }
 
@@ -171,9 +543,19 @@ func evalExpression(args []ast.Expression, env *object.Environment) []object.Obj
 	return result
 }
 
+// evalProgram binds every top-level function declaration before running the
+// program, so declarations can refer to each other in any order.
 func evalProgram(p *ast.Program, env *object.Environment) object.Object {
+	for _, statement := range p.Statements {
+		if isFunctionDeclaration(statement) {
+			Eval(statement, env)
+		}
+	}
 	var result object.Object
 	for _, statement := range p.Statements {
+		if isFunctionDeclaration(statement) {
+			continue
+		}
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object.Error:
@@ -185,6 +567,14 @@ func evalProgram(p *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
+func isFunctionDeclaration(statement ast.Statement) bool {
+	if export, ok := statement.(*ast.ExportStatement); ok {
+		statement = export.Statement
+	}
+	_, ok := statement.(*ast.FunctionStatement)
+	return ok
+}
+
 func evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range bs.Statements {
@@ -192,7 +582,7 @@ func evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.ERROR_OBJ || rt == object.RETURN_VALUE_OBJ {
+			if rt == object.ERROR_OBJ || rt == object.RETURN_VALUE_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -200,6 +590,210 @@ func evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.
 	return result
 }
 
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		matched, err := matchPattern(arm.Pattern, subject, armEnv)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return Eval(arm.Body, armEnv)
+	}
+	return newError("no match arm matched value %s", subject.Inspect())
+}
+
+// matchPattern reports whether value has the shape described by pattern,
+// binding the names in the pattern in env as it goes.
+func matchPattern(pattern ast.Expression, value object.Object, env *object.Environment) (bool, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, value)
+		}
+		return true, nil
+	case *ast.TypePattern:
+		if string(value.Type()) != pattern.Type {
+			return false, nil
+		}
+		if pattern.Pattern == nil {
+			return true, nil
+		}
+		return matchPattern(pattern.Pattern, value, env)
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok || len(array.Elements) < len(pattern.Elements) {
+			return false, nil
+		}
+		if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return false, nil
+		}
+		for i, element := range pattern.Elements {
+			if matched, err := matchPattern(element, array.Elements[i], env); !matched || err != nil {
+				return false, err
+			}
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			rest := append([]object.Object{}, array.Elements[len(pattern.Elements):]...)
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+		return true, nil
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+		for i, key := range pattern.Keys {
+			pair, ok := hash.Pairs[patternKey(key).HashKey()]
+			if !ok {
+				return false, nil
+			}
+			if matched, err := matchPattern(pattern.Values[i], pair.Value, env); !matched || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		literal := Eval(pattern, env)
+		if isError(literal) {
+			return false, literal.(*object.Error)
+		}
+		return literalMatches(literal, value), nil
+	}
+}
+
+func literalMatches(literal object.Object, value object.Object) bool {
+	if isNumber(literal) && isNumber(value) {
+		if literal.Type() == object.INTEGER_OBJ && value.Type() == object.INTEGER_OBJ {
+			return literal.(*object.Integer).Value == value.(*object.Integer).Value
+		}
+		return toFloat(literal) == toFloat(value)
+	}
+	switch literal := literal.(type) {
+	case *object.String:
+		str, ok := value.(*object.String)
+		return ok && str.Value == literal.Value
+	default:
+		return literal == value
+	}
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+		if result, done := evalLoopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+	if fs.Init != nil {
+		init := Eval(fs.Init, loopEnv)
+		if isError(init) {
+			return init
+		}
+	}
+	loopVariables := boundNames(fs.Init)
+	for {
+		if fs.Condition != nil {
+			condition := Eval(fs.Condition, loopEnv)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+		if result, done := evalLoopBody(fs.Body, loopEnv); done {
+			return result
+		}
+		// Like for-in, every iteration gets its own copy of the variables
+		// declared by init, so closures created in the body capture that
+		// iteration's values. The post statement updates the next copy.
+		next := object.NewEnclosedEnvironment(env)
+		for _, name := range loopVariables {
+			if value, ok := loopEnv.Get(name); ok {
+				next.Set(name, value)
+			}
+		}
+		loopEnv = next
+		if fs.Post != nil {
+			post := Eval(fs.Post, loopEnv)
+			if isError(post) {
+				return post
+			}
+		}
+	}
+}
+
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	collection, ok := iterable.(object.Iterable)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+	var result object.Object
+	collection.Iterate(func(key, value object.Object) bool {
+		// Every iteration gets its own scope so closures created in the
+		// body capture that iteration's variables.
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if fs.Key != nil {
+			loopEnv.Set(fs.Key.Value, key)
+			loopEnv.Set(fs.Value.Value, value)
+		} else if _, isHash := collection.(*object.Hash); isHash {
+			loopEnv.Set(fs.Value.Value, key)
+		} else {
+			loopEnv.Set(fs.Value.Value, value)
+		}
+		var done bool
+		result, done = evalLoopBody(fs.Body, loopEnv)
+		return !done
+	})
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// evalLoopBody runs one iteration and reports whether the loop has to stop,
+// along with the value to hand on: errors and return values propagate,
+// break ends the loop and continue moves on to the next iteration.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	switch result := Eval(body, env).(type) {
+	case *object.Error, *object.ReturnValue:
+		return result, true
+	case *object.Break:
+		return NULL, true
+	}
+	return nil, false
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -232,6 +826,18 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 	return &object.Hash{Pairs: pairs}
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -258,12 +864,48 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+// evalLogicalExpression only evaluates the right operand when the left one
+// does not already decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// evalNullishExpression yields the left operand unless it is null, and only
+// evaluates the right operand in that case.
+func evalNullishExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) || left != NULL {
+		return left
+	}
+	return Eval(node.Right, env)
+}
+
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalStringRepeat(left, right)
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringRepeat(right, left)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -286,7 +928,38 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftValue * rightValue}
 	case "/":
+		if rightValue == 0 {
+			return newError("division by zero: %d / %d", leftValue, rightValue)
+		}
 		return &object.Integer{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError("division by zero: %d %% %d", leftValue, rightValue)
+		}
+		return &object.Integer{Value: leftValue % rightValue}
+	case "**":
+		if rightValue < 0 {
+			return &object.Float{Value: math.Pow(float64(leftValue), float64(rightValue))}
+		}
+		power, ok := integerPower(leftValue, rightValue)
+		if !ok {
+			return newError("integer overflow: %d ** %d", leftValue, rightValue)
+		}
+		return &object.Integer{Value: power}
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<", ">>":
+		if rightValue < 0 || rightValue > 63 {
+			return newError("shift count out of range: %d %s %d", leftValue, operator, rightValue)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftValue << rightValue}
+		}
+		return &object.Integer{Value: leftValue >> rightValue}
 	case "==":
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
@@ -295,28 +968,141 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 		return nativeBoolToBooleanObject(leftValue > rightValue)
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// maxStringLength bounds the result of string repetition so a stray large
+// count reports an error instead of exhausting memory.
+const maxStringLength = 1 << 30
+
+// integerPower raises base to a non-negative exponent by squaring and
+// reports false if the result does not fit in an int64.
+func integerPower(base int64, exponent int64) (int64, bool) {
+	result := int64(1)
+	ok := true
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if result, ok = multiplyInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+		exponent >>= 1
+		// Any square still to be taken ends up in the result.
+		if exponent > 0 {
+			if base, ok = multiplyInt64(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+func multiplyInt64(a int64, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (product < 0) != ((a < 0) != (b < 0)) {
+		return 0, false
+	}
+	return product, true
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat promotes an Integer to float64; mixed arithmetic always happens
+// in floating point.
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
 	switch operator {
 	case "+":
 		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+func evalStringRepeat(str object.Object, count object.Object) object.Object {
+	value := str.(*object.String).Value
+	times := count.(*object.Integer).Value
+	if times < 0 {
+		return newError("negative repeat count: %d", times)
+	}
+	if times > 0 && int64(len(value)) > maxStringLength/times {
+		return newError("string repeat result too large: %d * %d bytes", times, len(value))
+	}
+	return &object.String{Value: strings.Repeat(value, int(times))}
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalTildePrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -336,11 +1122,22 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+}
+
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	integer, ok := right.(*object.Integer)
+	if !ok {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+	return &object.Integer{Value: ^integer.Value}
 }
 
 func nativeBoolToBooleanObject(val bool) *object.Boolean {
@@ -367,6 +1164,10 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+func newErrorAt(pos token.Position, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Pos: pos}
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -2,8 +2,11 @@ package evaluator
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/vshalt/arbok/ast"
 	"github.com/vshalt/arbok/lexer"
 	"github.com/vshalt/arbok/object"
 	"github.com/vshalt/arbok/parser"
@@ -29,6 +32,26 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15/3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"2 ** 62", 4611686018427387904},
+		{"(0 - 2) ** 63", -9223372036854775808},
+		{"(0 - 1) ** 9223372036854775807", -1},
+		{"0 ** 100", 0},
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"-16 >> 2", -4},
+		{"0xF0 >> 4 & 0x3", 3},
+		{"1 << 63 >> 63", -1},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -36,6 +59,67 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5},
+		{"2e3 + 1", 2001},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2},
+		{"float(3)", 3},
+		{`float("2.25")`, 2.25},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumericComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"0.1 + 0.2 == 0.3", false},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIntConversion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(7)", 7},
+		{`int("42")`, 42},
+		{`int("4.2")`, `cannot convert "4.2" to INTEGER`},
+		{"int(true)", "argument to `int` not supported, got BOOLEAN"},
+		{"int(1e30)", "cannot convert 1e+30 to INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -60,6 +144,30 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 1", true},
+		{"1 >= 2", false},
+		{"2 >= 1.5", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"abc" < "abd"`, true},
+		{`"abc" > "ab"`, true},
+		{`"B" < "a"`, true},
+		{`"" < "a"`, true},
+		{`"ab" <= "ab"`, true},
+		{`"ab" >= "b"`, false},
+		{`let s = "x"; s + "y" == "xy"`, true},
+		{`"1" == 1`, false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"false && 1 / 0", false},
+		{"0 || false", true},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -98,6 +206,10 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) {10}", nil},
 		{"if (1 > 2) {10} else {20}", 20},
 		{"if (1 < 2) {10} else {20}", 10},
+		{"if (1 > 2) {10} else if (2 > 1) {20} else {30}", 20},
+		{"if (1 > 2) {10} else if (2 > 3) {20} else {30}", 30},
+		{"if (1 > 2) {10} else if (2 > 3) {20}", nil},
+		{"let x = 3; if (x == 1) {10} else if (x == 2) {20} else if (x == 3) {30} else {40}", 30},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -142,6 +254,22 @@ func TestErrorHandling(t *testing.T) {
 		{"foobar;", "identifier not found: foobar"},
 		{`"foo" - "bar"`, "unknown operator: STRING - STRING"},
 		{`{"name": "Monkey"}[func(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"1 % 0", "division by zero: 1 % 0"},
+		{"2 ** 64", "integer overflow: 2 ** 64"},
+		{"2 ** 63", "integer overflow: 2 ** 63"},
+		{"10 ** 19", "integer overflow: 10 ** 19"},
+		{"3 ** 4611686018427387904", "integer overflow: 3 ** 4611686018427387904"},
+		{"true && undefined", "identifier not found: undefined"},
+		{"false || undefined", "identifier not found: undefined"},
+		{"1 << 64", "shift count out of range: 1 << 64"},
+		{"1 >> -1", "shift count out of range: 1 >> -1"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{"1 | 2.0", "unknown operator: INTEGER | FLOAT"},
+		{"1.0 ^ 1.0", "unknown operator: FLOAT ^ FLOAT"},
+		{"1.0 << 1", "unknown operator: FLOAT << INTEGER"},
+		{"1 >> 1.0", "unknown operator: INTEGER >> FLOAT"},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -156,6 +284,29 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLine   int
+		expectedColumn int
+	}{
+		{"foobar;", 1, 1},
+		{"let a = 1;\nlet b = a + true;", 2, 11},
+		{"let f = func(x) {\n  -x\n};\nf(true);", 2, 3},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned, got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.Line != tt.expectedLine || errObj.Pos.Column != tt.expectedColumn {
+			t.Errorf("wrong error position. expected=%d:%d, got=%s", tt.expectedLine, tt.expectedColumn, errObj.Pos)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -171,6 +322,232 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 1; a = 2; a;", 2},
+		{"let a = 1; let b = 1; a = b = 5; a + b;", 10},
+		{"let a = 1; a = a + 1;", 2},
+		{"let counter = 0; let inc = func() { counter = counter + 1 }; inc(); inc(); counter;", 2},
+		{"let x = 1; let f = func() { let x = 10; x = 20; x }; f() + x;", 21},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr[0] + arr[1] + arr[2];", 24},
+		{`let h = {"a": 1}; h["a"] = 2; h["b"] = 3; h["a"] + h["b"];`, 5},
+		{"let cache = {}; let remember = func(k, v) { cache[k] = v }; remember(1, 42); cache[1];", 42},
+		{"y = 5;", "cannot assign to undeclared identifier: y"},
+		{"let f = func() { z = 1 }; f();", "cannot assign to undeclared identifier: z"},
+		{"let arr = [1]; arr[1] = 2;", "array index out of range: 1"},
+		{`let arr = [1]; arr["0"] = 2;`, "array index must be INTEGER, got STRING"},
+		{`let s = "abc"; s[0] = "x";`, "index assignment not supported: STRING"},
+		{`let h = {}; h[func() {}] = 1;`, "unusable as hash key: FUNCTION"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { i = i + 1 }; i;", 5},
+		{"let sum = 0; for (let i = 1; i <= 10; i = i + 1) { sum = sum + i }; sum;", 55},
+		{"let i = 0; for (; i < 3;) { i = i + 1 }; i;", 3},
+		{"let i = 0; for (;;) { i = i + 1; if (i == 7) { break } }; i;", 7},
+		{"let i = 0; while (true) { i = i + 1; if (i > 3) { break; } }; i;", 4},
+		{"let sum = 0; for (let i = 0; i < 10; i = i + 1) { if (i % 2 == 0) { continue } sum = sum + i }; sum;", 25},
+		{"let n = 0; let i = 0; while (i < 10) { i = i + 1; if (i > 5) { continue; } n = n + 1 }; n;", 5},
+		{"let count = 0; for (let i = 0; i < 3; i = i + 1) { for (let j = 0; j < 3; j = j + 1) { if (j == i) { break } count = count + 1 } }; count;", 3},
+		{"let f = func() { let i = 0; while (true) { i = i + 1; if (i == 4) { return i * 10 } } }; f();", 40},
+		{"let i = 0; while (i < 100000) { i = i + 1 }; i;", 100000},
+		{"let i = 10; for (let i = 0; i < 3; i = i + 1) { }; i;", 10},
+		{"while (false) { 1 }", nil},
+		{"while (x) { 1 }", "identifier not found: x"},
+		{"let i = 0; while (true) { i = i + 1; if (i == 3) { i + true } }", "type mismatch: INTEGER + BOOLEAN"},
+		{"for (let i = 0; i < 3; i = i + y) { }", "identifier not found: y"},
+		{"let f = func() { while (false) { } }; f()", nil},
+		{"let f = func() { for (let i = 0; i < 3; i = i + 1) { } }; f()", nil},
+		{"let f = func() { for (x in []) { } }; f()", nil},
+		{"let f = func() { while (true) { break } }; f()", nil},
+		{"let f = func() { while (false) { } }; if (f() == 1) { 1 } else { 2 }", 2},
+		{"let f = func() { for (x in [1]) { continue } }; len([f()])", 1},
+		{"let fs = []; for (let i = 0; i < 3; i = i + 1) { fs = push(fs, func() { i }) }; fs[0]() * 100 + fs[1]() * 10 + fs[2]()", 12},
+		{"let fs = []; for (let i = 0; i < 3; i = i + 1) { fs = push(fs, func() { i }); i = i + 1 }; len(fs) * 10 + fs[0]()", 21},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestForInLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x }; sum;", 6},
+		{"let sum = 0; for (i, x in [10, 20, 30]) { sum = sum + i * x }; sum;", 80},
+		{`let out = ""; for (k in {"b": 2, "a": 1, "c": 3}) { out = out + k }; out;`, "abc"},
+		{`let out = ""; for (k, v in {"b": 2, "a": 1}) { out = out + "${k}=${v};" }; out;`, "a=1;b=2;"},
+		{`let out = ""; for (k in {3: 0, true: 0, "x": 0, -1: 0, 2.5: 0}) { out = out + "${k} " }; out;`, "true -1 2.5 3 x "},
+		{`let out = ""; for (ch in "héllo") { out = ch + out }; out;`, "olléh"},
+		{`let out = ""; for (i, ch in "añb") { out = out + "${i}${ch}" }; out;`, "0a1ñ2b"},
+		{"let sum = 0; for (i in range(5)) { sum = sum + i }; sum;", 10},
+		{"let sum = 0; for (i in range(2, 5)) { sum = sum + i }; sum;", 9},
+		{`let out = ""; for (i in range(10, 0, -3)) { out = out + "${i} " }; out;`, "10 7 4 1 "},
+		{"let n = 0; for (i in range(9223372036854775806, 9223372036854775807, 5)) { n = n + 1 }; n;", 1},
+		{"let sum = 0; for (x in [1, 2, 3, 4, 5]) { if (x == 2) { continue } if (x == 4) { break } sum = sum + x }; sum;", 4},
+		{"let f = func() { for (x in [1, 2, 3]) { if (x == 2) { return x * 100 } } }; f();", 200},
+		{"let fs = []; for (x in [1, 2, 3]) { fs = push(fs, func() { x }) }; fs[0]() + fs[2]();", 4},
+		{"let x = 5; for (x in [1, 2]) { }; x;", 5},
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{"for (x in [1, 2]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"range(1, 2, 0)", "range step must not be zero"},
+		{`range("a")`, "arguments to `range` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestHashKeysAndValues(t *testing.T) {
+	input := `let h = {"b": 2, "a": 1, 10: "ten"}; [keys(h), values(h), h];`
+	result, ok := testEval(input).(*object.Array)
+	if !ok {
+		t.Fatalf("result is not Array. got=%T", result)
+	}
+	expected := []string{`[10, a, b]`, `[ten, 1, 2]`, `{10: ten, a: 1, b: 2}`}
+	for i, want := range expected {
+		if result.Elements[i].Inspect() != want {
+			t.Errorf("wrong result[%d]. expected=%q, got=%q", i, want, result.Elements[i].Inspect())
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b;", 12},
+		{"let [a, b, ...rest] = [1, 2, 3, 4, 5]; a + b + len(rest) * 100 + rest[2];", 308},
+		{"let [a, ...rest] = [1]; len(rest);", 0},
+		{"let [a, b] = [1, 2, 3]; b;", 2},
+		{"let [a, b, c] = [1]; c;", nil},
+		{`let {name, age} = {"name": "Ann", "age": 30}; age;`, 30},
+		{`let {name, age} = {"name": "Ann", "age": 30}; name;`, "Ann"},
+		{`let {name: n, "full name": full} = {"name": "Bo", "full name": "Bo Li"}; n + "/" + full;`, "Bo/Bo Li"},
+		{`let {missing} = {}; missing;`, nil},
+		{`let [x, {id, tags: [tag, ...more]}] = [1, {"id": 7, "tags": [8, 9]}]; x + id + tag + more[0];`, 25},
+		{"let [h, ...t] = rest([1, 2, 3]); h;", 2},
+		{"let swap = func([a, b]) { [b, a] }; let [x, y] = swap([1, 2]); x * 10 + y;", 21},
+		{`let greet = func({name}, greeting = "hi") { greeting + " " + name }; greet({"name": "Al"});`, "hi Al"},
+		{"func sum([a, b], ...more) { a + b + len(more) } sum([1, 2], 0, 0);", 5},
+		{"let [a, b] = 5;", "cannot destructure INTEGER as ARRAY"},
+		{`let {a} = [1];`, "cannot destructure ARRAY as HASH"},
+		{"let f = func([a]) { a }; f({});", "cannot destructure HASH as ARRAY"},
+		{"let [a, b] = [1, x];", "identifier not found: x"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestStrictDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, c] = [1, 2];", "1:12: missing array element 2 in destructuring of [a, b, c]"},
+		{`let {name, age} = {"name": "Ann"};`, `1:12: missing hash key "age" in destructuring of {name, age}`},
+		{"let f = func([a, b]) { a }; f([1]);", "1:18: missing array element 1 in destructuring of [a, b]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalStrict(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if got := errObj.Pos.String() + ": " + errObj.Message; got != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+	testIntegerObject(t, testEvalStrict("let [a, ...rest] = [1]; a + len(rest);"), 1)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (2) { 1 => 10, 2 => 20, _ => 30 }", 20},
+		{"match (5) { 1 => 10, 2 => 20, _ => 30 }", 30},
+		{"match (-1) { -1 => 1, _ => 0 }", 1},
+		{"match (2.0) { 2 => 1, _ => 0 }", 1},
+		{`match ("b") { "a" => 1, "b" => 2, _ => 3 }`, 2},
+		{"match (false) { true => 1, false => 2 }", 2},
+		{"match (7) { n => n * 2 }", 14},
+		{`match ("s") { INTEGER => 1, STRING => 2, _ => 3 }`, 2},
+		{"match (if (false) { 1 }) { NULL => 1, _ => 2 }", 1},
+		{"match (4) { FLOAT(f) => 0, INTEGER(n) => n + 1 }", 5},
+		{"match ([]) { [] => 0, [x] => 1, [x, ...rest] => 2 }", 0},
+		{"match ([9]) { [] => 0, [x] => x, [x, ...rest] => 2 }", 9},
+		{"match ([1, 2, 3]) { [x] => 0, [x, ...rest] => x + len(rest) }", 3},
+		{"match ([1, 2, 3]) { [1, 2] => 0, [1, _, 3] => 1, _ => 2 }", 1},
+		{"match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }", 6},
+		{"match ([1, 2]) { [a, ..._] => a }", 1},
+		{`match ({"type": "data", "payload": 42}) { {type: "ping"} => 0, {type: "data", payload} => payload }`, 42},
+		{`match ({"type": "ping"}) { {type: "data", payload} => 1, {type: "ping"} => 2 }`, 2},
+		{`match ({"type": "data"}) { {type: "data", payload} => 1, _ => 2 }`, 2},
+		{`match ({"n": 3}) { {n: INTEGER(n)} if n > 2 => n * 10, {n} => n }`, 30},
+		{`match ({"n": 1}) { {n: INTEGER(n)} if n > 2 => n * 10, {n} => n }`, 1},
+		{"let classify = func(n) { match (n) { n if n < 0 => -1, 0 => 0, _ => 1 } }; classify(-5) * 100 + classify(0) * 10 + classify(9);", -99},
+		{"let sum = func(xs) { match (xs) { [] => 0, [head, ...tail] => head + sum(tail) } }; sum([1, 2, 3, 4]);", 10},
+		{"let n = 1; match (5) { n => n }; n;", 1},
+		{"match (3) { 1 => 1, 2 => 2 }", "no match arm matched value 3"},
+		{`match ([1]) { [] => 1, {a} => 2 }`, "no match arm matched value [1]"},
+		{"match (x) { _ => 1 }", "identifier not found: x"},
+		{"match (1) { n if n + true => 1 }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestOptionalIndexAndNullish(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let config = {"db": {"port": 5433}}; config?.["db"]?.["port"] ?? 5432;`, 5433},
+		{`let config = {"db": {}}; config?.["db"]?.["port"] ?? 5432;`, 5432},
+		{`let config = {}; config?.["db"]?.["port"] ?? 5432;`, 5432},
+		{`let config = {}; config?.["db"]?.["port"];`, nil},
+		{"[1, 2]?.[1]", 2},
+		{"[1, 2]?.[5] ?? 7", 7},
+		{"0 ?? 1", 0},
+		{"let f = func() { false }; if (f() ?? true) { 1 } else { 2 }", 2},
+		{"1 ?? missing", 1},
+		{`let c = 0; let bump = func() { c = c + 1; 1 }; let v = 5 ?? bump(); c;`, 0},
+		{`let c = 0; let bump = func() { c = c + 1; 1 }; let v = {}["x"] ?? bump(); c;`, 1},
+		{`let h = {}; h["a"]["b"];`, "index operator not supported: NULL"},
+		{`let h = {}; h?.["a"]["b"];`, "index operator not supported: NULL"},
+		{"missing ?? 1", "identifier not found: missing"},
+		{"5?.[0]", "index operator not supported: INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "func(x) {x + 2;}"
 	evaluated := testEval(input)
@@ -191,6 +568,47 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"func double(x) { x * 2 } double(21);", 42},
+		{"func add(a, b) { return a + b; }; add(2, 3);", 5},
+		{"let r = fact(5); func fact(n) { if (n <= 1) { 1 } else { n * fact(n - 1) } } r;", 120},
+		{`
+		let result = isEven(10);
+		func isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+		func isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+		if (result && isOdd(7)) { 1 } else { 0 }
+		`, 1},
+		{"let f = func() { func inner() { 7 } inner() }; f();", 7},
+		{"let base = 10; func addBase(x) { x + base } addBase(5);", 15},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	fn, ok := testEval("func square(x) { x * x } square").(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function.")
+	}
+	if fn.Name != "square" {
+		t.Errorf("function has wrong name. got=%q", fn.Name)
+	}
+	if fn.Inspect() != "func square(x){\n(x * x)\n}" {
+		t.Errorf("wrong Inspect. got=%q", fn.Inspect())
+	}
+	fn = testEval("let cube = func(x) { x * x * x }; cube").(*object.Function)
+	if fn.Name != "cube" {
+		t.Errorf("let-bound function has wrong name. got=%q", fn.Name)
+	}
+	fn = testEval("func(x) { x }").(*object.Function)
+	if fn.Name != "" {
+		t.Errorf("anonymous function has a name. got=%q", fn.Name)
+	}
+}
+
 func TestFunctionApplication(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -208,6 +626,387 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = func(a, b = 10) { a + b }; add(1);", 11},
+		{"let add = func(a, b = 10) { a + b }; add(1, 2);", 3},
+		{"let f = func(a, b = a * 2) { a + b }; f(5);", 15},
+		{"let x = 100; let f = func(a = x) { a }; let y = f(); y;", 100},
+		{"let count = func(...items) { len(items) }; count();", 0},
+		{"let count = func(...items) { len(items) }; count(1, 2, 3);", 3},
+		{"let f = func(first, ...rest) { first + len(rest) }; f(10, 1, 2);", 12},
+		{"let f = func(first, ...rest) { rest[1] }; f(1, 2, 3);", 3},
+		{"let f = func(a, b = 2, ...rest) { a + b + len(rest) }; f(1);", 3},
+		{"let f = func(a, b = 2, ...rest) { a + b + len(rest) }; f(1, 5, 0, 0);", 8},
+		{"let add = func(a, b) { a + b }; add(1);", "wrong number of arguments to `add`. got=1, expected=2"},
+		{"let add = func(a, b) { a + b }; add(1, 2, 3);", "wrong number of arguments to `add`. got=3, expected=2"},
+		{"func(a) { a }();", "wrong number of arguments. got=0, expected=1"},
+		{"func opt(a, b = 1) { a } opt();", "wrong number of arguments to `opt`. got=0, expected=1 to 2"},
+		{"func opt(a, b = 1) { a } opt(1, 2, 3);", "wrong number of arguments to `opt`. got=3, expected=1 to 2"},
+		{"func variadic(a, ...b) { a } variadic();", "wrong number of arguments to `variadic`. got=0, expected=at least 1"},
+		{"let f = func(a = missing) { a }; f();", "identifier not found: missing"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+
+	fn := testEval("let f = func(a, b = 1, ...c) { a }; f;").(*object.Function)
+	if fn.Inspect() != "func f(a, b = 1, ...c){\na\n}" {
+		t.Errorf("wrong Inspect. got=%q", fn.Inspect())
+	}
+}
+
+func TestArrowFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let double = x => x * 2; double(21);", 42},
+		{"let add = (x, y) => x + y; add(2, 3);", 5},
+		{"let answer = () => 42; answer();", 42},
+		{"let f = (a, b = 10) => a + b; f(1);", 11},
+		{"let count = (...xs) => len(xs); count(1, 2, 3);", 3},
+		{"let adder = x => y => x + y; adder(3)(4);", 7},
+		{"let apply = (f, v) => f(v); apply(x => x * x, 9);", 81},
+		{"let f = x => { let y = x + 1; y * 2 }; f(4);", 10},
+		{"(x => x + 1)(1)", 2},
+		{"let sum = 0; for (x in [1, 2, 3]) { let g = () => x; sum = sum + g() }; sum;", 6},
+		{"match (5) { n if n > 3 => n * 2, _ => 0 }", 10},
+		{"let first = ([a, b]) => a; first([7, 8]);", 7},
+		{`let get = ({"x": x}) => x; get({"x": 5});`, 5},
+		{`let f = ([a, {b: c}], d = 10) => a + c + d; f([1, {"b": 2}]);`, 13},
+		{"let pairs = [[1, 2], [3, 4]]; let sum = 0; for (p in pairs) { sum = sum + (([a, b]) => a * b)(p) }; sum;", 14},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestPipelineOperator(t *testing.T) {
+	prelude := `
+	let map = func(xs, f) { let out = []; for (x in xs) { out = push(out, f(x)) }; out };
+	let filter = func(xs, f) { let out = []; for (x in xs) { if (f(x)) { out = push(out, x) } }; out };
+	let sum = func(xs) { let total = 0; for (x in xs) { total = total + x }; total };
+	`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4] |> filter(x => x % 2 == 0) |> map(x => x * x) |> sum", 20},
+		{"[1, 2, 3] |> len", 3},
+		{"5 |> (x => x + 1)", 6},
+		{"let mk = func() { x => x * 10 }; 5 |> (mk())", 50},
+		{"let add = (a, b) => a + b; 1 |> add(2) |> add(3)", 6},
+		{"[] |> first ?? 0", nil},
+		{"let r = [3] |> first; r * 2", 6},
+		{"5 |> missing()", "identifier not found: missing"},
+		{"5 |> 6", "not a function: INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(prelude + tt.input)
+		testObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(1.5 * 2))`, `3.0`},
+		{`quote(unquote("a" + "b"))`, `"ab"`},
+		{`quote(unquote([1, "a"]))`, `[1, "a"]`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfix = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfix))`, `(8 + (4 + 4))`},
+		{`quote(f(unquote(1 + 1), [unquote(2 + 1)]))`, `f(2, [3])`},
+		{`let f = func(x) { quote(unquote(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("expected *object.Quote for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if quote.Node == nil {
+			t.Errorf("quote.Node is nil for %q", tt.input)
+			continue
+		}
+		if quote.Node.String() != tt.expected {
+			t.Errorf("not equal for %q. got=%q, want=%q", tt.input, quote.Node.String(), tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments to `quote`. got=2, expected=1"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments to `unquote`. got=2, expected=1"},
+		{`quote(unquote(missing))`, "identifier not found: missing"},
+		{`quote(unquote({"a": 1}))`, "cannot unquote HASH"},
+		{`unquote(1)`, "unquote called outside of quote"},
+		{`let m = func() { macro(x) { x } }; m()`, "macros can only be defined by top-level let statements"},
+	}
+	for _, tt := range errorTests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message, expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = func(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("wrong parameters. got=%s, %s", macro.Parameters[0], macro.Parameters[1])
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q. got=%q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(cond, cons, alt) {
+				quote(if (!(unquote(cond))) { unquote(cons); } else { unquote(alt); });
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`let twice = macro(x) { return quote(unquote(x) + unquote(x)); }; let f = func() { twice(g()) };`,
+			`let f = func() { g() + g() };`,
+		},
+		{
+			`let inc = macro(x) { quote(unquote(x) + 1) }; let double = macro(x) { quote(inc(unquote(x)) * 2) }; double(3);`,
+			`(3 + 1) * 2`,
+		},
+	}
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err.Message)
+			continue
+		}
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal for %q. want=%q, got=%q", tt.input, expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestMacroEvaluation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };
+		unless(1 > 2, 10, 20)`, 10},
+		{`let assert_eq = macro(actual, expected) {
+			quote(if (unquote(actual) != unquote(expected)) { "assertion failed" } else { "ok" })
+		};
+		let add = func(a, b) { a + b };
+		assert_eq(add(1, 2), 3)`, "ok"},
+	}
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		macroEnv := object.NewEnvironment()
+		DefineMacros(program, macroEnv)
+		expanded, err := ExpandMacros(program, macroEnv)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err.Message)
+			continue
+		}
+		evaluated := Eval(expanded, object.NewEnvironment())
+		testObject(t, evaluated, tt.expected)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(x) { quote(x) }; m(1, 2)`, "1:33: wrong number of arguments to `m`. got=2, expected=1"},
+		{`let m = macro(x) { 1 }; m(1)`, "1:26: macro `m` must return a QUOTE, got INTEGER"},
+		{`let m = macro() { }; m()`, "1:23: macro `m` must return a QUOTE, got NULL"},
+		{`let m = macro() { missing }; m()`, "1:19: identifier not found: missing"},
+		{`let m = macro() { quote(m()) }; m()`, "1:26: macro expansion of `m` nested more than 100 levels deep"},
+	}
+	for _, tt := range errorTests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected error for %q", tt.input)
+			continue
+		}
+		if got := fmt.Sprintf("%s: %s", err.Pos, err.Message); got != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/strings.arbok": `
+			import "helpers" as h;
+			export func shout(s) { s + h.bang }
+			export let [first, ...others] = ["a", "b", "c"];
+			let hidden = 1;
+		`,
+		"lib/helpers.arbok": `export let bang = "!";`,
+		"counter.arbok": `
+			export let state = {"loads": 0};
+			state["loads"] = state["loads"] + 1;
+		`,
+		"macros.arbok": `
+			let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };
+			export let answer = unless(false, 42, 0);
+		`,
+		"search/util.arbok": `export let double = x => x * 2;`,
+		"cycle/a.arbok":     `import "b" as b; export let a = 1;`,
+		"cycle/b.arbok":     `import "a" as a; export let b = 2;`,
+		"broken.arbok":      "let x = 1;\nlet y = x + missing;",
+		"syntax.arbok":      "let = 1;",
+		"main.arbok":        `import "main" as m;`,
+		"strict.arbok":      `export let x = [1][3];`,
+	}
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ModulePath = []string{filepath.Join(dir, "search")}
+	defer func() { ModulePath = nil }()
+	ResetModules()
+	defer ResetModules()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/strings" as s; s.shout("hi")`, "hi!"},
+		{`import "lib/strings" as s; [s.first, s.others]`, "[a, [b, c]]"},
+		{`import "lib/strings.arbok" as s; s["first"]`, "a"},
+		{`import "lib/strings" as s; s`, `module("lib/strings")`},
+		{`import "lib/strings" as s; s.hidden`, `module "lib/strings" has no export "hidden"`},
+		{`import "lib/strings" as s; s[1]`, "module member must be STRING, got INTEGER"},
+		{`import "lib/strings" as s; s.first = "z"`, "index assignment not supported: MODULE"},
+		{`import "counter" as a; import "counter" as b; [a == b, b.state["loads"]]`, "[true, 1]"},
+		{`import "counter" as c; c.state["loads"]`, "1"},
+		{`import "macros" as m; m.answer`, "42"},
+		{`import "util" as u; u.double(21)`, "42"},
+		{`let f = func() { import "util" as u; u.double(2) }; f()`, "4"},
+		{`import "missing" as m;`, `module "missing.arbok" not found in ` + dir + string(filepath.ListSeparator) + filepath.Join(dir, "search")},
+		{`import "cycle/a" as a;`, filepath.Join(dir, "cycle/b.arbok") + ":1:1: import cycle: " + filepath.Join(dir, "cycle/a.arbok") + " -> " + filepath.Join(dir, "cycle/b.arbok") + " -> " + filepath.Join(dir, "cycle/a.arbok")},
+		{`import "main" as m;`, "import cycle: " + filepath.Join(dir, "main.arbok") + " -> " + filepath.Join(dir, "main.arbok")},
+		{`import "broken" as b;`, filepath.Join(dir, "broken.arbok") + ":2:13: identifier not found: missing"},
+		{`import "syntax" as b;`, filepath.Join(dir, "syntax.arbok") + ":1:5: expected next token to be IDENTIFIER, got = instead"},
+		{`{"a": 1}.a`, "1"},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(dir, "main.arbok")
+		program := parser.New(lexer.NewWithFilename(file, tt.input)).ParseProgram()
+		evaluated := EvalMain(file, program, object.NewEnvironment())
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+			if errObj.Pos.Filename != file {
+				got = errObj.Pos.String() + ": " + got
+			}
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	file := filepath.Join(dir, "main.arbok")
+	program := parser.New(lexer.NewWithFilename(file, `import "counter" as c; c`)).ParseProgram()
+	cached := EvalMain(file, program, object.NewEnvironment())
+	ResetModules()
+	if reloaded := EvalMain(file, program, object.NewEnvironment()); reloaded == cached {
+		t.Errorf("module still cached after ResetModules")
+	}
+
+	strictEnv := object.NewEnvironment()
+	strictEnv.SetStrict(true)
+	program = parser.New(lexer.NewWithFilename(file, `import "strict" as s;`)).ParseProgram()
+	if errObj, ok := EvalMain(file, program, strictEnv).(*object.Error); !ok || errObj.Message != "array index out of range: 3" {
+		t.Errorf("module did not run in strict mode. got=%v", errObj)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = func(x) {
@@ -260,7 +1059,9 @@ func TestArrayIndexExpressions(t *testing.T) {
 		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
 		{"let myArray = [1, 2, 3]; let i=myArray[0]; myArray[1];", 2},
 		{"[1, 2, 3][3]", nil},
-		{"[1, 2, 3][-1]", nil},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", nil},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -273,6 +1074,90 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1, 3, 5]"},
+		{"[1, 2, 3, 4, 5][1::2]", "[2, 4]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:-1]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5, 4, 3, 2, 1]"},
+		{"[1, 2, 3, 4, 5][3:0:-1]", "[4, 3, 2]"},
+		{"[1, 2, 3, 4, 5][-1:-4:-2]", "[5, 3]"},
+		{"[1, 2, 3][1:10]", "[2, 3]"},
+		{"[1, 2, 3][-10:2]", "[1, 2]"},
+		{"[1, 2, 3][2:1]", "[]"},
+		{"[1, 2, 3][2::9223372036854775807]", "[3]"},
+		{"[1, 2, 3][::-9223372036854775807]", "[3]"},
+		{`"abc"[1::9223372036854775807]`, "b"},
+		{"[][:]", "[]"},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a;", "[1, 2, 3]"},
+		{"let i = 1; [1, 2, 3][i:i + 1]", "[2]"},
+		{`"hello"[1:4]`, "ell"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"héllo wörld"[-5:]`, "wörld"},
+		{`"日本語"[1:]`, "本語"},
+		{`""[0:5]`, ""},
+		{"let a = [1, 2, 3]; a[-1] = 30; a;", "[1, 2, 30]"},
+		{`let h = {}; h?.["x"]?.[1:]`, "null"},
+		{"[1, 2, 3][::0]", "slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "slice index must be INTEGER, got STRING"},
+		{`{"a": 1}[0:1]`, "slice operator not supported: HASH"},
+		{"5[1:2]", "slice operator not supported: INTEGER"},
+		{"let a = [1]; a[-2] = 0;", "array index out of range: -2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %q", tt.input)
+			continue
+		}
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStrictIndexing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3][-1]", "3"},
+		{"[1, 2, 3][1:]", "[2, 3]"},
+		{"[1, 2, 3][-3:3]", "[1, 2, 3]"},
+		{"[1, 2, 3][3]", "array index out of range: 3"},
+		{"[1, 2, 3][-4]", "array index out of range: -4"},
+		{"[1, 2, 3][1:10]", "slice index out of range: 10 with length 3"},
+		{"[1, 2, 3][-4:]", "slice index out of range: -4 with length 3"},
+		{`"abc"[0:4]`, "slice index out of range: 4 with length 3"},
+		{`{"a": 1}["b"]`, "null"},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[3]`, "string index out of range: 3"},
+		{"let f = func(a) { a[5] }; f([1])", "array index out of range: 5"},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalStrict(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "world";`
 	evaluated := testEval(input)
@@ -285,6 +1170,66 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringIndexAndRepeat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello"[0]`, "h"},
+		{`"hello"[4]`, "o"},
+		{`"hello"[-1]`, "o"},
+		{`"héllo"[1]`, "é"},
+		{`"日本語"[-2]`, "本"},
+		{`let s = "abc"; let i = 1; s[i + 1]`, "c"},
+		{`"hello"[5]`, "null"},
+		{`"hello"[-6]`, "null"},
+		{`""[0]`, "null"},
+		{`"ab" * 3`, "ababab"},
+		{`3 * "ab"`, "ababab"},
+		{`"ab" * 0`, ""},
+		{`"-" * 2 + ">"`, "-->"},
+		{`"ab" * -1`, "negative repeat count: -1"},
+		{`"ab" * 1073741824`, "string repeat result too large: 1073741824 * 2 bytes"},
+		{`"ab" * 1.5`, "type mismatch: STRING * FLOAT"},
+		{`"ab" * "c"`, "unknown operator: STRING * STRING"},
+		{`"hello"["a"]`, "index operator not supported: STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let user = {"name": "Ada"}; let count = 3; "Hello ${user["name"]}, you have ${count} messages"`, "Hello Ada, you have 3 messages"},
+		{`"${1 + 2} ${1.5} ${true} ${[1, "two"]}"`, "3 1.5 true [1, two]"},
+		{`let f = func(x) { x * 2 }; "${f(21)}!"`, "42!"},
+		{`"nested ${"inner ${1 + 1}"}"`, "nested inner 2"},
+		{`"cost: \${price}"`, "cost: ${price}"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String, got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -293,6 +1238,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("世界")`, 2},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, expected=1"},
 	}
@@ -365,6 +1312,11 @@ func TestHashIndexExpressions(t *testing.T) {
 		{`{5: 5}[5]`, 5},
 		{`{true: 5}[true]`, 5},
 		{`{false: 5}[false]`, 5},
+		{`{1: 5}[1.0]`, 5},
+		{`{2.0: 5}[2]`, 5},
+		{`{1.5: 5}[1.5]`, 5},
+		{`{1: 5}[1.5]`, nil},
+		{`len(keys({1: 1, 1.0: 2}))`, 1},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -386,6 +1338,19 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+func testEvalStrict(input string) object.Object {
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	env.SetStrict(true)
+	return Eval(program, env)
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	if obj.Type() == object.ERROR_OBJ {
 		fmt.Println(obj.Inspect())
@@ -402,6 +1367,19 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float, got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object value wrong. got=%g", result.Value)
+		return false
+	}
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not null, got=%T (%+v)", obj, obj)
@@ -409,6 +1387,43 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	}
 	return true
 }
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error, got=%T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
+// testObject checks obj against an expected value from a test table: an int
+// is an integer, nil is null and a string is either the value of a string
+// result or the message of an error.
+func testObject(t *testing.T, obj object.Object, expected interface{}) bool {
+	switch expected := expected.(type) {
+	case int:
+		return testIntegerObject(t, obj, int64(expected))
+	case nil:
+		return testNullObject(t, obj)
+	case string:
+		if str, ok := obj.(*object.String); ok {
+			if str.Value != expected {
+				t.Errorf("object value wrong. expected=%q, got=%q", expected, str.Value)
+				return false
+			}
+			return true
+		}
+		return testErrorObject(t, obj, expected)
+	default:
+		t.Errorf("unsupported expected value %T", expected)
+		return false
+	}
+}
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
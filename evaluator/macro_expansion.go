@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"github.com/vshalt/arbok/ast"
+	"github.com/vshalt/arbok/object"
+)
+
+// maxMacroDepth bounds how deeply macro expansions may expand into further
+// macro calls, so that a macro expanding into itself reports an error.
+const maxMacroDepth = 100
+
+// DefineMacros binds every top-level "let name = macro(...) {...};" in
+// program in env and removes those statements from the program.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := []ast.Statement{}
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			statements = append(statements, statement)
+			continue
+		}
+		literal, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+		env.Set(let.Name.Value, &object.Macro{Parameters: literal.Parameters, Body: literal.Body, Env: env})
+	}
+	program.Statements = statements
+}
+
+// ExpandMacros returns a copy of program in which every call of a macro
+// defined in env is replaced by the syntax the macro returns. Arguments are
+// passed to the macro as quotes of the unevaluated argument expressions.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return expandMacros(program, env, 0)
+}
+
+func expandMacros(program ast.Node, env *object.Environment, depth int) (ast.Node, *object.Error) {
+	var err *object.Error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+		macro, name, ok := macroCall(call, env)
+		if !ok {
+			return node
+		}
+		if depth >= maxMacroDepth {
+			err = newErrorAt(call.Pos(), "macro expansion of `%s` nested more than %d levels deep", name, maxMacroDepth)
+			return node
+		}
+		if len(call.Arguments) != len(macro.Parameters) {
+			err = newErrorAt(call.Pos(), "wrong number of arguments to `%s`. got=%d, expected=%d", name, len(call.Arguments), len(macro.Parameters))
+			return node
+		}
+		macroEnv := object.NewEnclosedEnvironment(macro.Env)
+		for i, param := range macro.Parameters {
+			macroEnv.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+		}
+		evaluated := unwrapReturnValue(Eval(macro.Body, macroEnv))
+		if isError(evaluated) {
+			err = evaluated.(*object.Error)
+			return node
+		}
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			err = newErrorAt(call.Pos(), "macro `%s` must return a QUOTE, got %s", name, evaluated.Type())
+			return node
+		}
+		result, expandErr := expandMacros(quote.Node, env, depth+1)
+		if expandErr != nil {
+			err = expandErr
+			return node
+		}
+		return result
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expanded, nil
+}
+
+func macroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, string, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, "", false
+	}
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, "", false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ident.Value, ok
+}
@@ -0,0 +1,221 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vshalt/arbok/ast"
+	"github.com/vshalt/arbok/lexer"
+	"github.com/vshalt/arbok/object"
+	"github.com/vshalt/arbok/parser"
+)
+
+// moduleExtension is added to import paths that do not name an extension.
+const moduleExtension = ".arbok"
+
+// ModulePath lists the directories searched for an imported module when it
+// is not found next to the importing file.
+var ModulePath []string
+
+var (
+	// modules caches every module loaded so far by absolute file path, so
+	// that each module is evaluated only once.
+	modules = map[string]*object.Module{}
+	// importStack holds the files of the modules currently being loaded,
+	// outermost first, to detect import cycles.
+	importStack []string
+)
+
+// ResetModules forgets every loaded module, so that the next import of a
+// file evaluates it again. The REPL and script runner call it before they
+// start.
+func ResetModules() {
+	modules = map[string]*object.Module{}
+	importStack = nil
+}
+
+// EvalMain evaluates program, the main script read from file, recording it
+// as being loaded so that a module importing it back reports a cycle.
+func EvalMain(file string, program ast.Node, env *object.Environment) object.Object {
+	if key, err := filepath.Abs(file); err == nil {
+		importStack = append(importStack, key)
+		defer func() { importStack = importStack[:len(importStack)-1] }()
+	}
+	return Eval(program, env)
+}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := importModule(node.Path.Value, node.Token.Pos.Filename, env.Strict())
+	if isError(module) {
+		return module
+	}
+	env.Set(node.Name.Value, module)
+	return nil
+}
+
+// importModule loads the module at path, which is resolved relative to the
+// directory of the importing file and then to each entry of ModulePath. A
+// module loaded for the first time runs in strict mode if strict is set.
+func importModule(path string, importer string, strict bool) object.Object {
+	file, err := resolveModule(path, importer)
+	if err != nil {
+		return err
+	}
+	key, absErr := filepath.Abs(file)
+	if absErr != nil {
+		return newError("cannot import %q: %s", path, absErr)
+	}
+	if module, ok := modules[key]; ok {
+		return module
+	}
+	for i, loading := range importStack {
+		if loading == key {
+			cycle := append(append([]string{}, importStack[i:]...), key)
+			for j := range cycle {
+				cycle[j] = displayPath(cycle[j])
+			}
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	source, readErr := os.ReadFile(file)
+	if readErr != nil {
+		return newError("cannot import %q: %s", path, readErr)
+	}
+	importStack = append(importStack, key)
+	defer func() { importStack = importStack[:len(importStack)-1] }()
+
+	exports, evalErr := evalModule(file, string(source), strict)
+	if evalErr != nil {
+		return evalErr
+	}
+	module := &object.Module{Name: path, Exports: exports}
+	modules[key] = module
+	return module
+}
+
+// resolveModule returns the first existing file for path, trying the
+// directory of importer (or the working directory when importer is empty)
+// before the directories of ModulePath.
+func resolveModule(path string, importer string) (string, *object.Error) {
+	if filepath.Ext(path) == "" {
+		path += moduleExtension
+	}
+	if filepath.IsAbs(path) {
+		if isFile(path) {
+			return path, nil
+		}
+		return "", newError("module %q not found", path)
+	}
+	dirs := append([]string{filepath.Dir(importer)}, ModulePath...)
+	for _, dir := range dirs {
+		if file := filepath.Join(dir, path); isFile(file) {
+			return file, nil
+		}
+	}
+	return "", newError("module %q not found in %s", path, strings.Join(dirs, string(filepath.ListSeparator)))
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// displayPath shortens an absolute path to one relative to the working
+// directory where possible.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// evalModule runs the module in source in a fresh environment, expanding its
+// macros first, and returns the values of its exported bindings.
+func evalModule(file string, source string, strict bool) (map[string]object.Object, *object.Error) {
+	p := parser.New(lexer.NewWithFilename(file, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		err := p.Errors()[0]
+		return nil, newErrorAt(err.Pos, "%s", err.Message)
+	}
+
+	macroEnv := object.NewEnvironment()
+	macroEnv.SetStrict(strict)
+	DefineMacros(program, macroEnv)
+	expanded, err := ExpandMacros(program, macroEnv)
+	if err != nil {
+		return nil, err
+	}
+	env := object.NewEnvironment()
+	env.SetStrict(strict)
+	if result := Eval(expanded, env); isError(result) {
+		return nil, result.(*object.Error)
+	}
+
+	exports := map[string]object.Object{}
+	for _, statement := range expanded.(*ast.Program).Statements {
+		export, ok := statement.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		for _, name := range boundNames(export.Statement) {
+			if value, ok := env.Get(name); ok {
+				exports[name] = value
+			}
+		}
+	}
+	return exports, nil
+}
+
+// boundNames returns the names a let or function statement binds.
+func boundNames(statement ast.Statement) []string {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		if statement.Pattern != nil {
+			return patternNames(statement.Pattern)
+		}
+		return []string{statement.Name.Value}
+	case *ast.FunctionStatement:
+		return []string{statement.Name.Value}
+	default:
+		return nil
+	}
+}
+
+func patternNames(pattern ast.Expression) []string {
+	names := []string{}
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		names = append(names, pattern.Value)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			names = append(names, patternNames(element)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest.Value)
+		}
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			names = append(names, patternNames(value)...)
+		}
+	}
+	return names
+}
+
+func evalModuleMember(module *object.Module, name object.Object) object.Object {
+	str, ok := name.(*object.String)
+	if !ok {
+		return newError("module member must be STRING, got %s", name.Type())
+	}
+	value, ok := module.Exports[str.Value]
+	if !ok {
+		return newError("module %q has no export %q", module.Name, str.Value)
+	}
+	return value
+}
@@ -0,0 +1,83 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/vshalt/arbok/ast"
+	"github.com/vshalt/arbok/object"
+	"github.com/vshalt/arbok/token"
+)
+
+// quote returns node unevaluated, except that every unquote(x) call inside
+// it is replaced by the syntax for the value of x.
+func quote(node ast.Node, env *object.Environment) object.Object {
+	var err *object.Error
+	node = ast.Modify(node, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil || !isSpecialForm(call, "unquote") {
+			return node
+		}
+		if len(call.Arguments) != 1 {
+			err = newErrorAt(call.Pos(), "wrong number of arguments to `unquote`. got=%d, expected=1", len(call.Arguments))
+			return node
+		}
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted.(*object.Error)
+			return node
+		}
+		converted, convErr := convertObjectToASTNode(unquoted, call.Token.Pos)
+		if convErr != nil {
+			err = convErr
+			return node
+		}
+		return converted
+	})
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: node}
+}
+
+// isSpecialForm reports whether call is a call of the quote or unquote
+// form called name; those are evaluated without evaluating their argument.
+func isSpecialForm(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// convertObjectToASTNode turns the value of an unquoted expression back into
+// syntax, with the tokens placed at pos.
+func convertObjectToASTNode(obj object.Object, pos token.Position) (ast.Node, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INTEGER, Literal: fmt.Sprintf("%d", obj.Value), Pos: pos}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect(), Pos: pos}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "[", Pos: pos}, Elements: []ast.Expression{}}
+		for _, element := range obj.Elements {
+			node, err := convertObjectToASTNode(element, pos)
+			if err != nil {
+				return nil, err
+			}
+			array.Elements = append(array.Elements, node.(ast.Expression))
+		}
+		return array, nil
+	case *object.Quote:
+		return obj.Node, nil
+	default:
+		return nil, newErrorAt(pos, "cannot unquote %s", obj.Type())
+	}
+}
@@ -1,46 +1,113 @@
 package lexer
 
 import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/vshalt/arbok/token"
 )
 
 type Lexer struct {
 	input        string
+	filename     string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+	width        int
+	line         int
+	column       int
+	errors       []Error
+
+	attachComments bool
+	// templateDepth holds, for every string interpolation being lexed, the
+	// number of unclosed braces inside its ${...}.
+	templateDepth []int
+}
+
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
 }
 
 func New(input string) *Lexer {
-	l := Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+func NewWithFilename(filename string, input string) *Lexer {
+	l := Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return &l
 }
 
+func (l *Lexer) Input() string {
+	return l.input
+}
+
+func (l *Lexer) Filename() string {
+	return l.filename
+}
+
+// StartAtLine numbers the lines of the input from line instead of 1, for
+// input that continues earlier source such as the entries of a REPL session.
+// It must be called before the first token is read.
+func (l *Lexer) StartAtLine(line int) {
+	l.line = line
+}
+
+// AttachComments makes NextToken record skipped comments on the tokens
+// around them instead of discarding them.
+func (l *Lexer) AttachComments(enabled bool) {
+	l.attachComments = enabled
+}
+
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
+func (l *Lexer) addError(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
+		l.width = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, l.width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += l.width
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
-func isLetter(ch byte) bool {
-	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -51,23 +118,114 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
-	for isDigit(l.ch) {
+	if l.ch == '0' {
+		if base, name := numberBase(l.peekChar()); base != 0 {
+			pos := l.currentPosition()
+			l.readChar()
+			l.readChar()
+			if l.readDigits(base, name) == 0 {
+				l.addError(pos, "%s literal has no digits", name)
+			}
+			return token.INTEGER, l.input[position:l.position]
+		}
+	}
+	tokenType := token.TokenType(token.INTEGER)
+	pos := l.currentPosition()
+	l.readDigits(10, "decimal")
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
 		l.readChar()
+		l.readDigits(10, "decimal")
 	}
-	return l.input[position:l.position]
+	if (l.ch == 'e' || l.ch == 'E') && l.atExponent() {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits(10, "decimal")
+	}
+	// Leading zeros once made a literal octal; reject them rather than
+	// silently reading 010 as ten.
+	literal := l.input[position:l.position]
+	if tokenType == token.INTEGER && len(literal) > 1 && literal[0] == '0' {
+		l.addError(pos, "leading zeros are not allowed in decimal literal %s, use the 0o prefix for octal", literal)
+	}
+	return tokenType, literal
+}
+
+func numberBase(ch rune) (int, string) {
+	switch ch {
+	case 'x', 'X':
+		return 16, "hexadecimal"
+	case 'o', 'O':
+		return 8, "octal"
+	case 'b', 'B':
+		return 2, "binary"
+	default:
+		return 0, ""
+	}
+}
+
+// readDigits consumes the digits of a number in the given base along with
+// any '_' separators, reporting misplaced separators and digits that are
+// out of range for the base. It returns the number of digits read.
+func (l *Lexer) readDigits(base int, name string) int {
+	digits := 0
+	for isDigit(l.ch) || base == 16 && isHexDigit(l.ch) || l.ch == '_' {
+		if l.ch == '_' {
+			if !isDigitOf(l.peekChar(), base) {
+				l.addError(l.currentPosition(), "'_' must separate successive digits")
+			}
+		} else {
+			if !isDigitOf(l.ch, base) {
+				l.addError(l.currentPosition(), "invalid digit %q in %s literal", l.ch, name)
+			}
+			digits += 1
+		}
+		l.readChar()
+	}
+	return digits
+}
+
+func isDigitOf(ch rune, base int) bool {
+	return isHexDigit(ch) && int(hexValue(ch)) < base
+}
+
+func (l *Lexer) atExponent() bool {
+	next := l.readPosition
+	if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+		next += 1
+	}
+	return next < len(l.input) && isDigit(rune(l.input[next]))
 }
 
 func (l *Lexer) NextToken() token.Token {
+	leading := l.skipComments()
+	tok := l.readToken()
+	if l.attachComments {
+		tok.Leading = leading
+		if tok.Type != token.EOF {
+			tok.Trailing = l.readTrailingComments()
+		}
+	}
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 	l.skipWhiteSpace()
+	pos := l.currentPosition()
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = newToken(token.EQUAL, string(ch)+string(l.ch))
+		} else if l.peekChar() == '>' {
+			tok = l.readTwoCharToken(token.ARROW)
 		} else {
 			tok = newToken(token.ASSIGN, string(l.ch))
 		}
@@ -84,20 +242,76 @@ func (l *Lexer) NextToken() token.Token {
 	case '-':
 		tok = newToken(token.MINUS, string(l.ch))
 	case '*':
-		tok = newToken(token.ASTERISK, string(l.ch))
+		if l.peekChar() == '*' {
+			tok = l.readTwoCharToken(token.POWER)
+		} else {
+			tok = newToken(token.ASTERISK, string(l.ch))
+		}
 	case '/':
 		tok = newToken(token.SLASH, string(l.ch))
+	case '%':
+		tok = newToken(token.PERCENT, string(l.ch))
 	case '>':
-		tok = newToken(token.GREATER_THAN, string(l.ch))
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.GREATER_EQUAL)
+		case '>':
+			tok = l.readTwoCharToken(token.SHIFT_RIGHT)
+		default:
+			tok = newToken(token.GREATER_THAN, string(l.ch))
+		}
 	case '<':
-		tok = newToken(token.LESS_THAN, string(l.ch))
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.LESS_EQUAL)
+		case '<':
+			tok = l.readTwoCharToken(token.SHIFT_LEFT)
+		default:
+			tok = newToken(token.LESS_THAN, string(l.ch))
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, string(l.ch))
+		}
+	case '|':
+		switch l.peekChar() {
+		case '|':
+			tok = l.readTwoCharToken(token.OR)
+		case '>':
+			tok = l.readTwoCharToken(token.PIPELINE)
+		default:
+			tok = newToken(token.PIPE, string(l.ch))
+		}
+	case '^':
+		tok = newToken(token.CARET, string(l.ch))
+	case '~':
+		tok = newToken(token.TILDE, string(l.ch))
 	case '(':
 		tok = newToken(token.LPAREN, string(l.ch))
 	case ')':
 		tok = newToken(token.RPAREN, string(l.ch))
 	case '{':
+		if depth := len(l.templateDepth); depth > 0 {
+			l.templateDepth[depth-1] += 1
+		}
 		tok = newToken(token.LBRACE, string(l.ch))
 	case '}':
+		depth := len(l.templateDepth)
+		if depth > 0 && l.templateDepth[depth-1] == 0 {
+			l.templateDepth = l.templateDepth[:depth-1]
+			literal, interpolated := l.readString(pos)
+			tok.Literal = literal
+			tok.Type = token.TEMPLATE_TAIL
+			if interpolated {
+				tok.Type = token.TEMPLATE_MIDDLE
+			}
+			break
+		}
+		if depth > 0 {
+			l.templateDepth[depth-1] -= 1
+		}
 		tok = newToken(token.RBRACE, string(l.ch))
 	case ',':
 		tok = newToken(token.COMMA, string(l.ch))
@@ -106,8 +320,32 @@ func (l *Lexer) NextToken() token.Token {
 	case ':':
 		tok = newToken(token.COLON, string(l.ch))
 	case '"':
+		literal, interpolated := l.readString(pos)
+		tok.Literal = literal
+		tok.Type = token.STRING
+		if interpolated {
+			tok.Type = token.TEMPLATE_HEAD
+		}
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString(pos)
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			l.readChar()
+			l.readChar()
+			tok = newToken(token.ELLIPSIS, "...")
+		} else {
+			tok = newToken(token.DOT, string(l.ch))
+		}
+	case '?':
+		switch l.peekChar() {
+		case '.':
+			tok = l.readTwoCharToken(token.QUESTION_DOT)
+		case '?':
+			tok = l.readTwoCharToken(token.NULLISH)
+		default:
+			tok = newToken(token.ILLEGAL, string(l.ch))
+		}
 	case '[':
 		tok = newToken(token.LBRACKET, string(l.ch))
 	case ']':
@@ -119,16 +357,18 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdentifier(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INTEGER
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, string(l.ch))
 		}
 	}
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
@@ -138,17 +378,206 @@ func (l *Lexer) skipWhiteSpace() {
 	}
 }
 
-func (l *Lexer) readString() string {
+func (l *Lexer) atComment() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+func (l *Lexer) skipComments() []token.Comment {
+	var comments []token.Comment
+	for {
+		l.skipWhiteSpace()
+		if !l.atComment() {
+			return comments
+		}
+		comments = append(comments, l.readComment())
+	}
+}
+
+// readTrailingComments collects the comments that follow a token on the
+// same line; anything after the line break leads the next token instead.
+func (l *Lexer) readTrailingComments() []token.Comment {
+	var comments []token.Comment
+	for {
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+			l.readChar()
+		}
+		if !l.atComment() {
+			return comments
+		}
+		comment := l.readComment()
+		comments = append(comments, comment)
+		if strings.HasPrefix(comment.Text, "//") {
+			return comments
+		}
+	}
+}
+
+func (l *Lexer) readComment() token.Comment {
+	pos := l.currentPosition()
+	position := l.position
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return token.Comment{Text: strings.TrimRight(l.input[position:l.position], "\r"), Pos: pos}
+	}
+	l.readChar()
+	l.readChar()
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			l.addError(pos, "unterminated block comment")
+			return token.Comment{Text: l.input[position:l.position], Pos: pos}
+		}
+		l.readChar()
+	}
+	l.readChar()
+	l.readChar()
+	return token.Comment{Text: l.input[position:l.position], Pos: pos}
+}
+
+// readString reads string contents up to the closing quote or up to the
+// next ${, in which case it reports true and leaves the lexer on the '{'
+// so the interpolated expression is lexed as ordinary tokens.
+func (l *Lexer) readString(start token.Position) (string, bool) {
+	var out strings.Builder
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return out.String(), false
+		case 0:
+			l.addError(start, "unterminated string literal")
+			return out.String(), false
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				l.templateDepth = append(l.templateDepth, 0)
+				return out.String(), true
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+func (l *Lexer) readRawString(start token.Position) string {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '`' {
+			break
+		}
+		if l.ch == 0 {
+			l.addError(start, "unterminated raw string literal")
 			break
 		}
 	}
 	return l.input[position:l.position]
 }
 
+var simpleEscapes = map[rune]rune{
+	'a':  '\a',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+	'0':  0,
+	'\\': '\\',
+	'\'': '\'',
+	'"':  '"',
+	'$':  '$',
+}
+
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.currentPosition()
+	l.readChar()
+	if ch, ok := simpleEscapes[l.ch]; ok {
+		out.WriteRune(ch)
+		return
+	}
+	switch l.ch {
+	case 0:
+		return
+	case 'x':
+		l.readCodePoint(out, pos, 2)
+	case 'u':
+		if l.peekChar() == '{' {
+			l.readChar()
+			l.readBracedCodePoint(out, pos)
+		} else {
+			l.readCodePoint(out, pos, 4)
+		}
+	case 'U':
+		l.readCodePoint(out, pos, 8)
+	default:
+		l.addError(pos, "unknown escape sequence: \\%c", l.ch)
+		out.WriteRune(l.ch)
+	}
+}
+
+func (l *Lexer) readCodePoint(out *strings.Builder, pos token.Position, digits int) {
+	var value rune
+	for i := 0; i < digits; i++ {
+		if !isHexDigit(l.peekChar()) {
+			l.addError(pos, "escape sequence needs %d hex digits", digits)
+			return
+		}
+		l.readChar()
+		value = value*16 + hexValue(l.ch)
+	}
+	l.writeCodePoint(out, pos, value)
+}
+
+func (l *Lexer) readBracedCodePoint(out *strings.Builder, pos token.Position) {
+	var value rune
+	digits := 0
+	for isHexDigit(l.peekChar()) && digits < 6 {
+		l.readChar()
+		value = value*16 + hexValue(l.ch)
+		digits += 1
+	}
+	if digits == 0 || l.peekChar() != '}' {
+		l.addError(pos, "malformed \\u{...} escape sequence")
+		return
+	}
+	l.readChar()
+	l.writeCodePoint(out, pos, value)
+}
+
+func (l *Lexer) writeCodePoint(out *strings.Builder, pos token.Position, value rune) {
+	if !utf8.ValidRune(value) {
+		l.addError(pos, "escape sequence is an invalid code point: %U", value)
+		return
+	}
+	out.WriteRune(value)
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F'
+}
+
+func hexValue(ch rune) rune {
+	switch {
+	case isDigit(ch):
+		return ch - '0'
+	case ch >= 'a' && ch <= 'f':
+		return ch - 'a' + 10
+	default:
+		return ch - 'A' + 10
+	}
+}
+
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return newToken(tokenType, string(ch)+string(l.ch))
+}
+
 func newToken(tokenType token.TokenType, literal string) token.Token {
 	return token.Token{Type: tokenType, Literal: literal}
 }
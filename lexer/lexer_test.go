@@ -15,7 +15,7 @@ let add = func(x, y) {
 
 let result = add(five, ten);
 
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -153,3 +153,463 @@ if (5 < 10) {
 	}
 
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + 10;`
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"x", 2, 3},
+		{"+", 2, 5},
+		{"10", 2, 7},
+		{";", 2, 9},
+		{"", 2, 10},
+	}
+
+	l := NewWithFilename("main.arb", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position mismatch. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+		if tok.Pos.Filename != "main.arb" {
+			t.Fatalf("tests[%d] - filename mismatch. got=%q", i, tok.Pos.Filename)
+		}
+	}
+}
+
+func TestStartAtLine(t *testing.T) {
+	l := New("x\n  y")
+	l.StartAtLine(4)
+	for _, expected := range []string{"4:1", "5:3", "5:4"} {
+		tok := l.NextToken()
+		if tok.Pos.String() != expected {
+			t.Fatalf("wrong position for %q. expected=%s, got=%s", tok.Literal, expected, tok.Pos)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := `let größe = "héllo, 世界"; größe + 名前;`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENTIFIER, "größe", 5},
+		{token.ASSIGN, "=", 11},
+		{token.STRING, "héllo, 世界", 13},
+		{token.SEMICOLON, ";", 24},
+		{token.IDENTIFIER, "größe", 26},
+		{token.PLUS, "+", 32},
+		{token.IDENTIFIER, "名前", 34},
+		{token.SEMICOLON, ";", 36},
+		{token.EOF, "", 37},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column mismatch. expected=%d, got=%d", i, tt.expectedColumn, tok.Pos.Column)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"café"`, "café"},
+		{`"\u{1F600}"`, "\U0001F600"},
+		{`"\x41\U00000042"`, "AB"},
+		{"`raw\\n\nstring`", "raw\\n\nstring"},
+		{"\"multi\nline\"", "multi\nline"},
+	}
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expected, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("tests[%d] - unexpected lexer errors: %v", i, l.Errors())
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedColumn  int
+	}{
+		{`let s = "open`, "unterminated string literal", 9},
+		{"let s = `open", "unterminated raw string literal", 9},
+		{`"bad \q escape"`, `unknown escape sequence: \q`, 6},
+		{`"\u12"`, "escape sequence needs 4 hex digits", 2},
+		{`"\u{D800}"`, "escape sequence is an invalid code point: U+D800", 2},
+	}
+	for i, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("tests[%d] - expected 1 error, got=%v", i, errors)
+		}
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("tests[%d] - wrong message. expected=%q, got=%q", i, tt.expectedMessage, errors[0].Message)
+		}
+		if errors[0].Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong column. expected=%d, got=%d", i, tt.expectedColumn, errors[0].Pos.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ x / 2;
+`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INTEGER, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.SLASH, "/"},
+		{token.INTEGER, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Leading != nil || tok.Trailing != nil {
+			t.Fatalf("tests[%d] - comments attached without AttachComments", i)
+		}
+	}
+}
+
+func TestCommentTrivia(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ x;`
+
+	l := New(input)
+	l.AttachComments(true)
+
+	let := l.NextToken()
+	if len(let.Leading) != 1 || let.Leading[0].Text != "// leading comment" {
+		t.Fatalf("wrong leading comments on let. got=%+v", let.Leading)
+	}
+	if let.Leading[0].Pos.Line != 1 || let.Leading[0].Pos.Column != 1 {
+		t.Errorf("wrong comment position. got=%s", let.Leading[0].Pos)
+	}
+	for i := 0; i < 3; i++ {
+		l.NextToken()
+	}
+	semicolon := l.NextToken()
+	if len(semicolon.Trailing) != 1 || semicolon.Trailing[0].Text != "// trailing comment" {
+		t.Fatalf("wrong trailing comments on semicolon. got=%+v", semicolon.Trailing)
+	}
+	x := l.NextToken()
+	if len(x.Leading) != 1 || x.Leading[0].Text != "/* block\n   comment */" {
+		t.Fatalf("wrong leading comments on x. got=%+v", x.Leading)
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("x /* never closed")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("expected EOF, got=%q", tok.Type)
+	}
+	errors := l.Errors()
+	if len(errors) != 1 || errors[0].Message != "unterminated block comment" {
+		t.Fatalf("wrong errors. got=%v", errors)
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `1 1.5 0.25 3e8 6.02E23 1e-3 2.5e+2 1.foo 2e`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTEGER, "1"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "0.25"},
+		{token.FLOAT, "3e8"},
+		{token.FLOAT, "6.02E23"},
+		{token.FLOAT, "1e-3"},
+		{token.FLOAT, "2.5e+2"},
+		{token.INTEGER, "1"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "foo"},
+		{token.INTEGER, "2"},
+		{token.IDENTIFIER, "e"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIntegerBases(t *testing.T) {
+	input := `0xFF 0o17 0b1010 1_000_000 0x_dead_beef 1_000.5`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTEGER, "0xFF"},
+		{token.INTEGER, "0o17"},
+		{token.INTEGER, "0b1010"},
+		{token.INTEGER, "1_000_000"},
+		{token.INTEGER, "0x_dead_beef"},
+		{token.FLOAT, "1_000.5"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedColumn  int
+	}{
+		{"0b102", "invalid digit '2' in binary literal", 5},
+		{"0o8", "invalid digit '8' in octal literal", 3},
+		{"0x", "hexadecimal literal has no digits", 1},
+		{"1__000", "'_' must separate successive digits", 2},
+		{"100_", "'_' must separate successive digits", 4},
+		{"010", "leading zeros are not allowed in decimal literal 010, use the 0o prefix for octal", 1},
+		{"0_7", "leading zeros are not allowed in decimal literal 0_7, use the 0o prefix for octal", 1},
+	}
+	for i, tt := range tests {
+		l := New(tt.input)
+		l.NextToken()
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("tests[%d] - expected 1 error, got=%v", i, errors)
+		}
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("tests[%d] - wrong message. expected=%q, got=%q", i, tt.expectedMessage, errors[0].Message)
+		}
+		if errors[0].Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong column. expected=%d, got=%d", i, tt.expectedColumn, errors[0].Pos.Column)
+		}
+	}
+}
+
+func TestMultiCharOperators(t *testing.T) {
+	input := `a <= b >= c % d ** e && f || g * h ...i => j?.[k] ?? l |> m`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENTIFIER, "a"},
+		{token.LESS_EQUAL, "<="},
+		{token.IDENTIFIER, "b"},
+		{token.GREATER_EQUAL, ">="},
+		{token.IDENTIFIER, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENTIFIER, "d"},
+		{token.POWER, "**"},
+		{token.IDENTIFIER, "e"},
+		{token.AND, "&&"},
+		{token.IDENTIFIER, "f"},
+		{token.OR, "||"},
+		{token.IDENTIFIER, "g"},
+		{token.ASTERISK, "*"},
+		{token.IDENTIFIER, "h"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENTIFIER, "i"},
+		{token.ARROW, "=>"},
+		{token.IDENTIFIER, "j"},
+		{token.QUESTION_DOT, "?."},
+		{token.LBRACKET, "["},
+		{token.IDENTIFIER, "k"},
+		{token.RBRACKET, "]"},
+		{token.NULLISH, "??"},
+		{token.IDENTIFIER, "l"},
+		{token.PIPELINE, "|>"},
+		{token.IDENTIFIER, "m"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `while for break continue in match macro import export as forever`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IN, "in"},
+		{token.MATCH, "match"},
+		{token.MACRO, "macro"},
+		{token.IDENTIFIER, "import"},
+		{token.IDENTIFIER, "export"},
+		{token.IDENTIFIER, "as"},
+		{token.IDENTIFIER, "forever"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	input := `a & b | c ^ ~d << 2 >> 1`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENTIFIER, "a"},
+		{token.AMPERSAND, "&"},
+		{token.IDENTIFIER, "b"},
+		{token.PIPE, "|"},
+		{token.IDENTIFIER, "c"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENTIFIER, "d"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INTEGER, "2"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INTEGER, "1"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"Hello ${user["name"]}, you have ${ {"n": count}["n"] } messages" "\${x}"`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "Hello "},
+		{token.IDENTIFIER, "user"},
+		{token.LBRACKET, "["},
+		{token.STRING, "name"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_MIDDLE, ", you have "},
+		{token.LBRACE, "{"},
+		{token.STRING, "n"},
+		{token.COLON, ":"},
+		{token.IDENTIFIER, "count"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "n"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, " messages"},
+		{token.STRING, "${x}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype mismatch. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal mismatch. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
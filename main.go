@@ -1,11 +1,29 @@
 package main
 
 import (
+	"flag"
 	"os"
+	"path/filepath"
 
+	"github.com/vshalt/arbok/evaluator"
 	"github.com/vshalt/arbok/repl"
 )
 
 func main() {
-	repl.Start(os.Stdin, os.Stdout)
+	var strict bool
+	var modulePath string
+	flag.BoolVar(&strict, "strict", false, "report missing destructured values and out-of-range indexes as errors")
+	flag.StringVar(&modulePath, "path", os.Getenv("ARBOK_PATH"), "directories searched for imported modules, separated by "+string(filepath.ListSeparator))
+	flag.Parse()
+	if modulePath != "" {
+		evaluator.ModulePath = filepath.SplitList(modulePath)
+	}
+
+	if flag.NArg() > 0 {
+		if !repl.RunFile(flag.Arg(0), os.Stderr, strict) {
+			os.Exit(1)
+		}
+		return
+	}
+	repl.Start(os.Stdin, os.Stdout, strict)
 }
@@ -1,8 +1,9 @@
 package object
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store  map[string]Object
+	outer  *Environment
+	strict bool
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -18,6 +19,31 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign updates the nearest enclosing binding of name and reports whether
+// such a binding exists.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// SetStrict turns strict mode on or off for the environment and for the
+// environments enclosed in it afterwards.
+func (e *Environment) SetStrict(strict bool) {
+	e.strict = strict
+}
+
+// Strict reports whether code evaluated in the environment runs in strict
+// mode.
+func (e *Environment) Strict() bool {
+	return e.strict
+}
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
 	return &Environment{store: s, outer: nil}
@@ -26,5 +52,6 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.strict = outer.strict
 	return env
 }
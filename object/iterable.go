@@ -0,0 +1,120 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Iterable is implemented by the objects a for-in loop can walk over. Iterate
+// calls fn with the key and value of each element in iteration order and
+// stops as soon as fn returns false.
+type Iterable interface {
+	Iterate(fn func(key, value Object) bool)
+}
+
+func (a *Array) Iterate(fn func(key, value Object) bool) {
+	for i := 0; i < len(a.Elements); i++ {
+		if !fn(&Integer{Value: int64(i)}, a.Elements[i]) {
+			return
+		}
+	}
+}
+
+// Iterate walks the string one code point at a time; keys are code point
+// indexes, not byte offsets.
+func (s *String) Iterate(fn func(key, value Object) bool) {
+	i := int64(0)
+	for _, ch := range s.Value {
+		if !fn(&Integer{Value: i}, &String{Value: string(ch)}) {
+			return
+		}
+		i += 1
+	}
+}
+
+// Iterate visits the pairs of the hash in the order of SortedPairs.
+func (h *Hash) Iterate(fn func(key, value Object) bool) {
+	for _, pair := range h.SortedPairs() {
+		if !fn(pair.Key, pair.Value) {
+			return
+		}
+	}
+}
+
+// SortedPairs returns the pairs of the hash ordered by key: booleans first,
+// then numbers in numeric order, then strings in lexicographic order.
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return keyLess(pairs[i].Key, pairs[j].Key) })
+	return pairs
+}
+
+func keyRank(key Object) int {
+	switch key.(type) {
+	case *Boolean:
+		return 0
+	case *Integer, *Float:
+		return 1
+	case *String:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func keyLess(a, b Object) bool {
+	if keyRank(a) != keyRank(b) {
+		return keyRank(a) < keyRank(b)
+	}
+	switch a := a.(type) {
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value
+		}
+		// 1 sorts before 1.0.
+		return float64(a.Value) <= b.(*Float).Value
+	case *Float:
+		if b, ok := b.(*Float); ok {
+			return a.Value < b.Value
+		}
+		return a.Value < float64(b.(*Integer).Value)
+	case *String:
+		return a.Value < b.(*String).Value
+	default:
+		return a.Inspect() < b.Inspect()
+	}
+}
+
+// Range is the lazy sequence of integers produced by the range builtin,
+// running from Start up to but not including End in increments of Step.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+func (r *Range) Iterate(fn func(key, value Object) bool) {
+	i := int64(0)
+	for value := r.Start; r.Step > 0 && value < r.End || r.Step < 0 && value > r.End; value += r.Step {
+		if !fn(&Integer{Value: i}, &Integer{Value: value}) {
+			return
+		}
+		if next := value + r.Step; next < value != (r.Step < 0) {
+			return
+		}
+		i += 1
+	}
+}
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -18,3 +21,62 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with same content have different hash")
 	}
 }
+
+func TestNumberHashKey(t *testing.T) {
+	tests := []struct {
+		integer int64
+		float   float64
+		equal   bool
+	}{
+		{1, 1.0, true},
+		{0, -0.0, true},
+		{-3, -3.0, true},
+		{1 << 62, 1 << 62, true},
+		{1, 1.5, false},
+		{0, 0.5, false},
+	}
+	for _, tt := range tests {
+		integer := &Integer{Value: tt.integer}
+		float := &Float{Value: tt.float}
+		if (integer.HashKey() == float.HashKey()) != tt.equal {
+			t.Errorf("hash keys of %d and %g: expected equal=%t", tt.integer, tt.float, tt.equal)
+		}
+	}
+	if (&Float{Value: math.Inf(1)}).HashKey() == (&Float{Value: math.MaxFloat64}).HashKey() {
+		t.Errorf("hash keys of +Inf and MaxFloat64 are equal")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{1.5, "1.5"},
+		{-0.25, "-0.25"},
+		{1e21, "1e+21"},
+	}
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %g. expected=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestArrayInspect(t *testing.T) {
+	tests := []struct {
+		array    *Array
+		expected string
+	}{
+		{&Array{Elements: []Object{}}, "[]"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, "[1, a]"},
+		{&Array{Elements: []Object{&Array{Elements: []Object{&Boolean{Value: true}}}}}, "[[true]]"},
+	}
+	for _, tt := range tests {
+		if tt.array.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expected, tt.array.Inspect())
+		}
+	}
+}
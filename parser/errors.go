@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vshalt/arbok/token"
+)
+
+type ParseError struct {
+	Pos      token.Position
+	Expected []token.TokenType
+	Found    token.Token
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Format renders the error together with the offending line of source.
+func (e *ParseError) Format(source string) string {
+	return token.FormatError(source, e.Pos, e.Message)
+}
+
+func expectedMessage(expected []token.TokenType, found token.TokenType) string {
+	if len(expected) == 1 {
+		return fmt.Sprintf("expected next token to be %s, got %s instead", expected[0], found)
+	}
+	names := make([]string, len(expected))
+	for i, t := range expected {
+		names[i] = string(t)
+	}
+	return fmt.Sprintf("expected next token to be one of %s, got %s instead", strings.Join(names, ", "), found)
+}
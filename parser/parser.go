@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/vshalt/arbok/ast"
 	"github.com/vshalt/arbok/lexer"
@@ -12,26 +15,52 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	PIPELINE
+	NULLISH
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
+	BITWISE_OR
+	BITWISE_XOR
+	BITWISE_AND
+	SHIFT
 	SUM
 	PRODUCT
 	PREFIX
+	POWER
 	CALL
 	INDEX
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQUAL:        EQUALS,
-	token.NOT_EQUAL:    EQUALS,
-	token.LESS_THAN:    LESSGREATER,
-	token.GREATER_THAN: LESSGREATER,
-	token.PLUS:         SUM,
-	token.MINUS:        SUM,
-	token.ASTERISK:     PRODUCT,
-	token.SLASH:        PRODUCT,
-	token.LPAREN:       CALL,
-	token.LBRACKET:     INDEX,
+	token.ASSIGN:        ASSIGN,
+	token.PIPELINE:      PIPELINE,
+	token.NULLISH:       NULLISH,
+	token.OR:            LOGICAL_OR,
+	token.AND:           LOGICAL_AND,
+	token.EQUAL:         EQUALS,
+	token.NOT_EQUAL:     EQUALS,
+	token.LESS_THAN:     LESSGREATER,
+	token.GREATER_THAN:  LESSGREATER,
+	token.LESS_EQUAL:    LESSGREATER,
+	token.GREATER_EQUAL: LESSGREATER,
+	token.PIPE:          BITWISE_OR,
+	token.CARET:         BITWISE_XOR,
+	token.AMPERSAND:     BITWISE_AND,
+	token.SHIFT_LEFT:    SHIFT,
+	token.SHIFT_RIGHT:   SHIFT,
+	token.PLUS:          SUM,
+	token.MINUS:         SUM,
+	token.ASTERISK:      PRODUCT,
+	token.SLASH:         PRODUCT,
+	token.PERCENT:       PRODUCT,
+	token.POWER:         POWER,
+	token.LPAREN:        CALL,
+	token.LBRACKET:      INDEX,
+	token.QUESTION_DOT:  INDEX,
+	token.DOT:           INDEX,
 }
 
 type (
@@ -43,30 +72,50 @@ type Parser struct {
 	l         *lexer.Lexer
 	currToken token.Token
 	peekToken token.Token
-	errors    []string
+	errors    []*ParseError
+
+	lexerErrors int
+	currInvalid bool
+	peekInvalid bool
+	// recovering is set after a syntax error and suppresses further errors
+	// until the parser resynchronizes at a statement boundary.
+	recovering bool
+	blockDepth int
+	// loopDepth counts the loops enclosing the current position inside the
+	// innermost function, so break and continue can be checked.
+	loopDepth int
+	// noLambda stops an identifier or parenthesized group followed by =>
+	// from being read as an arrow function, which would swallow the => of
+	// a match arm after its guard.
+	noLambda bool
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*ParseError{}}
 	p.nextToken()
 	p.nextToken()
 
 	p.prefixParseFns = map[token.TokenType]prefixParseFn{}
 	p.registerPrefix(token.IDENTIFIER, p.parseIdentifier)
 	p.registerPrefix(token.INTEGER, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 
 	p.infixParseFns = map[token.TokenType]infixParseFn{}
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -77,8 +126,24 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQUAL, p.parseInfixExpression)
 	p.registerInfix(token.LESS_THAN, p.parseInfixExpression)
 	p.registerInfix(token.GREATER_THAN, p.parseInfixExpression)
+	p.registerInfix(token.LESS_EQUAL, p.parseInfixExpression)
+	p.registerInfix(token.GREATER_EQUAL, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PIPELINE, p.parsePipelineExpression)
+	p.registerInfix(token.DOT, p.parseDotExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.QUESTION_DOT, p.parseOptionalIndexExpression)
 
 	return p
 }
@@ -91,17 +156,40 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
-func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", p.peekToken, t)
-	p.errors = append(p.errors, msg)
+
+func (p *Parser) addError(err *ParseError) {
+	if p.recovering {
+		return
+	}
+	p.recovering = true
+	p.errors = append(p.errors, err)
+}
+
+func (p *Parser) currError(format string, a ...interface{}) {
+	p.addError(&ParseError{Pos: p.currToken.Pos, Found: p.currToken, Message: fmt.Sprintf(format, a...)})
+}
+
+func (p *Parser) peekError(expected ...token.TokenType) {
+	p.addError(&ParseError{
+		Pos:      p.peekToken.Pos,
+		Expected: expected,
+		Found:    p.peekToken,
+		Message:  expectedMessage(expected, p.peekToken.Type),
+	})
 }
 
 func (p *Parser) nextToken() {
 	p.currToken = p.peekToken
+	p.currInvalid = p.peekInvalid
 	p.peekToken = p.l.NextToken()
+	p.peekInvalid = len(p.l.Errors()) > p.lexerErrors
+	for _, err := range p.l.Errors()[p.lexerErrors:] {
+		p.errors = append(p.errors, &ParseError{Pos: err.Pos, Message: err.Message})
+	}
+	p.lexerErrors = len(p.l.Errors())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -113,17 +201,77 @@ func (p *Parser) ParseProgram() *ast.Program {
 		if statement != nil {
 			program.Statements = append(program.Statements, statement)
 		}
+		if p.recovering {
+			p.synchronize()
+		}
 		p.nextToken()
 	}
 	return program
 }
 
+// statementStarts are the tokens that begin a new statement; the parser
+// resynchronizes in front of them after a syntax error.
+var statementStarts = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.FUNCTION: true,
+}
+
+// synchronize skips tokens until the end of the broken statement: a ';',
+// the '}' closing the enclosing block, or the start of a new statement.
+// Braces opened while skipping are skipped as a whole.
+func (p *Parser) synchronize() {
+	nesting := 0
+	for !p.currTokenIs(token.EOF) {
+		if nesting == 0 {
+			if p.currTokenIs(token.SEMICOLON) || p.currTokenIs(token.RBRACE) && p.blockDepth > 0 {
+				break
+			}
+			if statementStarts[p.peekToken.Type] || p.peekTokenIs(token.RBRACE) && p.blockDepth > 0 {
+				break
+			}
+		}
+		if p.peekTokenIs(token.LBRACE) {
+			nesting += 1
+		} else if p.peekTokenIs(token.RBRACE) && nesting > 0 {
+			nesting -= 1
+		}
+		p.nextToken()
+	}
+	p.recovering = false
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.IDENTIFIER:
+		if p.currStatementWord("import") {
+			return p.parseImportStatement()
+		}
+		if p.currStatementWord("export") {
+			return p.parseExportStatement()
+		}
+		return p.parseExpressionStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENTIFIER) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -131,27 +279,403 @@ func (p *Parser) parseStatement() ast.Statement {
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	statement := &ast.LetStatement{Token: p.currToken}
-	if !p.expectPeek(token.IDENTIFIER) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		statement.Pattern = p.parsePattern()
+	} else if p.expectPeek(token.IDENTIFIER) {
+		statement.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	} else {
+		return statement
 	}
-	statement.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-	if !p.expectPeek(token.ASSIGN) {
-		return nil
+	if p.recovering || !p.expectPeek(token.ASSIGN) {
+		return statement
 	}
 	p.nextToken()
 	statement.Value = p.parseExpression(LOWEST)
-	for !p.currTokenIs(token.SEMICOLON) {
+	if fn, ok := statement.Value.(*ast.FunctionLiteral); ok && statement.Name != nil {
+		fn.Name = statement.Name.Value
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return statement
 }
 
+// parsePattern parses the target of a destructuring binding: an identifier,
+// an array pattern or a hash pattern.
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.currToken.Type {
+	case token.IDENTIFIER:
+		return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern(p.parsePattern)
+	case token.LBRACE:
+		return p.parseHashPattern(p.parsePattern)
+	default:
+		p.currError("expected identifier or destructuring pattern, got %s instead", p.currToken.Type)
+		return nil
+	}
+}
+
+// parseArrayPattern parses an array pattern whose elements are parsed by
+// parseElement, so the same shape serves destructuring and match arms.
+func (p *Parser) parseArrayPattern(parseElement func() ast.Expression) ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.currToken}
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.currTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENTIFIER) {
+				return pattern
+			}
+			pattern.Rest = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+			if p.peekTokenIs(token.COMMA) {
+				p.currError("rest element ...%s must be the last element", pattern.Rest.Value)
+				return pattern
+			}
+			break
+		}
+		element := parseElement()
+		if element == nil {
+			return pattern
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBRACKET) {
+			p.peekError(token.COMMA, token.RBRACKET)
+			return pattern
+		}
+	}
+	p.expectPeek(token.RBRACKET)
+	return pattern
+}
+
+func (p *Parser) parseHashPattern(parseValue func() ast.Expression) ast.Expression {
+	pattern := &ast.HashPattern{Token: p.currToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		var key ast.Expression
+		switch p.currToken.Type {
+		case token.IDENTIFIER:
+			key = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		case token.STRING:
+			key = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+		default:
+			p.currError("expected identifier or string as hash pattern key, got %s instead", p.currToken.Type)
+			return pattern
+		}
+		value := key
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			value = parseValue()
+			if value == nil {
+				return pattern
+			}
+		} else if _, ok := key.(*ast.StringLiteral); ok {
+			p.peekError(token.COLON)
+			return pattern
+		}
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBRACE) {
+			p.peekError(token.COMMA, token.RBRACE)
+			return pattern
+		}
+	}
+	p.nextToken()
+	return pattern
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.currToken}
+	if !p.expectPeek(token.LPAREN) {
+		return expression
+	}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return expression
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return expression
+	}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := &ast.MatchArm{Pattern: p.parseMatchPattern()}
+		if arm.Pattern == nil || p.recovering {
+			return expression
+		}
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			noLambda := p.noLambda
+			p.noLambda = true
+			arm.Guard = p.parseExpression(LOWEST)
+			p.noLambda = noLambda
+		}
+		if !p.expectPeek(token.ARROW) {
+			return expression
+		}
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBRACE) {
+			p.peekError(token.COMMA, token.RBRACE)
+			return expression
+		}
+	}
+	p.nextToken()
+	return expression
+}
+
+// typePatterns are the object type names that act as type tests in match
+// patterns.
+var typePatterns = map[string]bool{
+	"INTEGER":  true,
+	"FLOAT":    true,
+	"BOOLEAN":  true,
+	"STRING":   true,
+	"ARRAY":    true,
+	"HASH":     true,
+	"FUNCTION": true,
+	"BUILTIN":  true,
+	"NULL":     true,
+	"RANGE":    true,
+}
+
+// parseMatchPattern parses the pattern of a match arm: a literal, a type
+// test, an array or hash shape, or a binding, where _ matches anything.
+func (p *Parser) parseMatchPattern() ast.Expression {
+	switch p.currToken.Type {
+	case token.IDENTIFIER:
+		if !typePatterns[p.currToken.Literal] {
+			return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		}
+		pattern := &ast.TypePattern{Token: p.currToken, Type: p.currToken.Literal}
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			p.nextToken()
+			pattern.Pattern = p.parseMatchPattern()
+			if pattern.Pattern == nil {
+				return pattern
+			}
+			p.expectPeek(token.RPAREN)
+		}
+		return pattern
+	case token.INTEGER:
+		return p.parseIntegerLiteral()
+	case token.FLOAT:
+		return p.parseFloatLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INTEGER) && !p.peekTokenIs(token.FLOAT) {
+			p.peekError(token.INTEGER, token.FLOAT)
+			return nil
+		}
+		return p.parsePrefixExpression()
+	case token.LBRACKET:
+		return p.parseArrayPattern(p.parseMatchPattern)
+	case token.LBRACE:
+		return p.parseHashPattern(p.parseMatchPattern)
+	default:
+		p.currError("expected match pattern, got %s instead", p.currToken.Type)
+		return nil
+	}
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	statement := &ast.ReturnStatement{Token: p.currToken}
 	p.nextToken()
 	statement.ReturnValue = p.parseExpression(LOWEST)
-	for !p.currTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	statement := &ast.WhileStatement{Token: p.currToken}
+	if !p.expectPeek(token.LPAREN) {
+		return statement
+	}
+	p.nextToken()
+	statement.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return statement
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return statement
+	}
+	statement.Body = p.parseLoopBody()
+	return statement
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	forToken := p.currToken
+	if !p.expectPeek(token.LPAREN) {
+		return &ast.ForStatement{Token: forToken}
+	}
+	p.nextToken()
+	if p.currTokenIs(token.IDENTIFIER) && (p.peekTokenIs(token.IN) || p.peekTokenIs(token.COMMA)) {
+		return p.parseForInStatement(forToken)
+	}
+	statement := &ast.ForStatement{Token: forToken}
+	if !p.currTokenIs(token.SEMICOLON) {
+		if p.currTokenIs(token.LET) {
+			statement.Init = p.parseLetStatement()
+		} else {
+			statement.Init = p.parseExpressionStatement()
+		}
+		if !p.currTokenIs(token.SEMICOLON) {
+			p.peekError(token.SEMICOLON)
+			return statement
+		}
+	}
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		statement.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return statement
+	}
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		statement.Post = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return statement
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return statement
+	}
+	statement.Body = p.parseLoopBody()
+	return statement
+}
+
+func (p *Parser) parseForInStatement(forToken token.Token) *ast.ForInStatement {
+	statement := &ast.ForInStatement{Token: forToken}
+	statement.Value = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENTIFIER) {
+			return statement
+		}
+		statement.Key = statement.Value
+		statement.Value = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	}
+	if !p.expectPeek(token.IN) {
+		return statement
+	}
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return statement
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return statement
+	}
+	statement.Body = p.parseLoopBody()
+	return statement
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth += 1
+	defer func() { p.loopDepth -= 1 }()
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	statement := &ast.BreakStatement{Token: p.currToken}
+	if p.loopDepth == 0 {
+		p.currError("break outside of loop")
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	statement := &ast.ContinueStatement{Token: p.currToken}
+	if p.loopDepth == 0 {
+		p.currError("continue outside of loop")
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+// currStatementWord reports whether the current token is the identifier
+// word starting a statement. import, export and as are not reserved, so
+// import and export start a statement only when the next token cannot
+// continue an expression; otherwise they are ordinary names.
+func (p *Parser) currStatementWord(word string) bool {
+	if p.currToken.Literal != word {
+		return false
+	}
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.RBRACE, token.EOF:
+		return false
+	}
+	_, ok := p.infixParseFns[p.peekToken.Type]
+	return !ok
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	statement := &ast.ImportStatement{Token: p.currToken}
+	if !p.expectPeek(token.STRING) {
+		return statement
+	}
+	statement.Path = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+	if !p.peekTokenIs(token.IDENTIFIER) || p.peekToken.Literal != "as" {
+		p.addError(&ParseError{
+			Pos:     p.peekToken.Pos,
+			Found:   p.peekToken,
+			Message: fmt.Sprintf("expected next token to be as, got %s instead", p.peekToken.Type),
+		})
+		return statement
+	}
+	p.nextToken()
+	if !p.expectPeek(token.IDENTIFIER) {
+		return statement
+	}
+	statement.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
+}
+
+// parseExportStatement parses export followed by a let or function
+// statement. Only top-level bindings can be exported.
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	statement := &ast.ExportStatement{Token: p.currToken}
+	if p.blockDepth > 0 {
+		p.currError("export is only allowed at the top level")
+		return statement
+	}
+	switch {
+	case p.peekTokenIs(token.LET):
 		p.nextToken()
+		statement.Statement = p.parseLetStatement()
+	case p.peekTokenIs(token.FUNCTION):
+		p.nextToken()
+		if !p.peekTokenIs(token.IDENTIFIER) {
+			p.peekError(token.IDENTIFIER)
+			return statement
+		}
+		statement.Statement = p.parseFunctionStatement()
+	default:
+		p.peekError(token.LET, token.FUNCTION)
 	}
 	return statement
 }
@@ -185,65 +709,318 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	if p.peekTokenIs(token.ARROW) && !p.noLambda {
+		lit := newArrowFunction(p.currToken)
+		lit.Parameters = []ast.Expression{ident}
+		return p.parseArrowBody(lit)
+	}
+	return ident
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.currToken, Value: p.currTokenIs(token.TRUE)}
 }
 
+// parseGroupedExpression parses either a parenthesized expression or the
+// parameter list of an arrow function, which is only known once the
+// closing parenthesis is followed by =>.
 func (p *Parser) parseGroupedExpression() ast.Expression {
-	p.nextToken()
-	expr := p.parseExpression(LOWEST)
+	start := p.currToken
+	noLambda := p.noLambda
+	p.noLambda = false
+	elements := []ast.Expression{}
+	var rest *ast.Identifier
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		if p.currTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENTIFIER) {
+				break
+			}
+			rest = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+			break
+		}
+		elements = append(elements, p.parseExpression(LOWEST))
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	p.noLambda = noLambda
 	if !p.expectPeek(token.RPAREN) {
+		if len(elements) > 0 {
+			return elements[0]
+		}
 		return nil
 	}
-	return expr
+	if p.peekTokenIs(token.ARROW) && !p.noLambda {
+		return p.parseArrowFunction(start, elements, rest)
+	}
+	if len(elements) != 1 || rest != nil {
+		p.peekError(token.ARROW)
+		return nil
+	}
+	return elements[0]
+}
+
+// newArrowFunction returns the function literal for an arrow function
+// starting at tok. It carries a func token so that it prints like any other
+// function literal.
+func newArrowFunction(tok token.Token) *ast.FunctionLiteral {
+	return &ast.FunctionLiteral{Token: token.Token{Type: token.FUNCTION, Literal: "func", Pos: tok.Pos}}
+}
+
+// parseArrowFunction turns the elements of a parenthesized group into the
+// parameters of an arrow function: names, destructuring patterns, name =
+// default pairs and a trailing rest parameter.
+func (p *Parser) parseArrowFunction(start token.Token, elements []ast.Expression, rest *ast.Identifier) ast.Expression {
+	lit := newArrowFunction(start)
+	lit.Parameters = []ast.Expression{}
+	lit.Rest = rest
+	for _, element := range elements {
+		switch element := element.(type) {
+		case *ast.Identifier:
+			if len(lit.Defaults) > 0 {
+				p.addError(&ParseError{
+					Pos:     element.Pos(),
+					Found:   element.Token,
+					Message: fmt.Sprintf("parameter %s without a default value follows a parameter with one", element),
+				})
+				return lit
+			}
+			lit.Parameters = append(lit.Parameters, element)
+		case *ast.AssignExpression:
+			name, ok := element.Target.(*ast.Identifier)
+			if !ok {
+				p.addError(&ParseError{Pos: element.Pos(), Found: element.Token, Message: fmt.Sprintf("invalid parameter %s", element)})
+				return lit
+			}
+			if lit.Defaults == nil {
+				lit.Defaults = map[string]ast.Expression{}
+			}
+			lit.Parameters = append(lit.Parameters, name)
+			lit.Defaults[name.Value] = element.Value
+		case *ast.ArrayLiteral, *ast.HashLiteral:
+			pattern, ok := literalPattern(element)
+			if !ok {
+				p.addError(&ParseError{Pos: element.Pos(), Message: fmt.Sprintf("invalid parameter %s", element)})
+				return lit
+			}
+			if len(lit.Defaults) > 0 {
+				p.addError(&ParseError{
+					Pos:     element.Pos(),
+					Message: fmt.Sprintf("parameter %s without a default value follows a parameter with one", pattern),
+				})
+				return lit
+			}
+			lit.Parameters = append(lit.Parameters, pattern)
+		default:
+			if element != nil {
+				p.addError(&ParseError{Pos: element.Pos(), Message: fmt.Sprintf("invalid parameter %s", element)})
+			}
+			return lit
+		}
+	}
+	return p.parseArrowBody(lit)
+}
+
+// literalPattern converts an array or hash literal read in the parameter
+// list of an arrow function into the destructuring pattern it spells, the
+// same pattern a func parameter list builds. Elements and values must be
+// names or nested patterns, and hash keys names or strings.
+func literalPattern(expr ast.Expression) (ast.Expression, bool) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		return expr, true
+	case *ast.ArrayLiteral:
+		pattern := &ast.ArrayPattern{Token: expr.Token}
+		for _, element := range expr.Elements {
+			converted, ok := literalPattern(element)
+			if !ok {
+				return nil, false
+			}
+			pattern.Elements = append(pattern.Elements, converted)
+		}
+		return pattern, true
+	case *ast.HashLiteral:
+		// The pairs of a hash literal are unordered, so the keys are put
+		// back in source order.
+		keys := make([]ast.Expression, 0, len(expr.Pairs))
+		for key := range expr.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := keys[i].Pos(), keys[j].Pos()
+			return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+		})
+		pattern := &ast.HashPattern{Token: expr.Token}
+		for _, key := range keys {
+			switch key.(type) {
+			case *ast.Identifier, *ast.StringLiteral:
+			default:
+				return nil, false
+			}
+			value, ok := literalPattern(expr.Pairs[key])
+			if !ok {
+				return nil, false
+			}
+			pattern.Keys = append(pattern.Keys, key)
+			pattern.Values = append(pattern.Values, value)
+		}
+		return pattern, true
+	default:
+		return nil, false
+	}
+}
+
+// parseArrowBody parses what follows the => of an arrow function: a block,
+// or a single expression that becomes the function's result.
+func (p *Parser) parseArrowBody(lit *ast.FunctionLiteral) ast.Expression {
+	p.nextToken()
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		lit.Body = p.parseBlockStatement()
+		return lit
+	}
+	lit.Body = &ast.BlockStatement{Token: p.currToken}
+	p.nextToken()
+	statement := &ast.ExpressionStatement{Token: p.currToken, Expression: p.parseExpression(LOWEST)}
+	lit.Body.Statements = []ast.Statement{statement}
+	return lit
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.currToken}
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return expression
 	}
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return expression
 	}
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return expression
 	}
 	expression.Consequence = p.parseBlockStatement()
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
+		if p.peekTokenIs(token.IF) {
+			// else if (...) {...} is shorthand for an else block holding
+			// just the nested if expression.
+			p.nextToken()
+			alternative := &ast.BlockStatement{Token: p.currToken}
+			statement := &ast.ExpressionStatement{Token: p.currToken, Expression: p.parseIfExpression()}
+			alternative.Statements = []ast.Statement{statement}
+			expression.Alternative = alternative
+			return expression
+		}
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			return expression
 		}
 		expression.Alternative = p.parseBlockStatement()
 	}
 	return expression
+}
 
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	statement := &ast.FunctionStatement{Token: p.currToken}
+	p.nextToken()
+	statement.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	lit := &ast.FunctionLiteral{Token: statement.Token, Name: statement.Name.Value}
+	statement.Function = p.parseFunction(lit)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return statement
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
-	lit := &ast.FunctionLiteral{Token: p.currToken}
+	return p.parseFunction(&ast.FunctionLiteral{Token: p.currToken})
+}
+
+// parseFunction parses the parameter list and body of lit, starting just
+// before the opening parenthesis.
+func (p *Parser) parseFunction(lit *ast.FunctionLiteral) *ast.FunctionLiteral {
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return lit
 	}
-	lit.Parameters = p.parseFunctionParameter()
+	p.parseFunctionParameters(lit)
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return lit
 	}
+	// A loop around the function literal does not make break or continue
+	// valid inside its body.
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 	return lit
 }
 
+// parseMacroLiteral parses macro(params) { body }. Macro parameters receive
+// their arguments unevaluated, so they are plain identifiers only.
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macro := &ast.MacroLiteral{Token: p.currToken, Parameters: []*ast.Identifier{}}
+	lit := p.parseFunction(&ast.FunctionLiteral{Token: p.currToken})
+	if lit.Rest != nil || len(lit.Defaults) > 0 {
+		p.addError(&ParseError{
+			Pos:     macro.Pos(),
+			Found:   macro.Token,
+			Message: "macro parameters cannot have default values or be rest parameters",
+		})
+	}
+	for _, param := range lit.Parameters {
+		ident, ok := param.(*ast.Identifier)
+		if !ok {
+			p.addError(&ParseError{
+				Pos:     param.Pos(),
+				Found:   macro.Token,
+				Message: fmt.Sprintf("macro parameter must be an identifier, got %s", param),
+			})
+			continue
+		}
+		macro.Parameters = append(macro.Parameters, ident)
+	}
+	macro.Body = lit.Body
+	return macro
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.currToken}
+	str.Parts = []ast.Expression{&ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}}
+	for {
+		if p.peekTokenIs(token.TEMPLATE_MIDDLE) || p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.currError("empty string interpolation")
+			return str
+		}
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.addError(&ParseError{
+				Pos:      p.peekToken.Pos,
+				Expected: []token.TokenType{token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL},
+				Found:    p.peekToken,
+				Message:  fmt.Sprintf("expected } to close string interpolation, got %s instead", p.peekToken.Type),
+			})
+			return str
+		}
+		p.nextToken()
+		str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal})
+		if p.currTokenIs(token.TEMPLATE_TAIL) {
+			return str
+		}
+	}
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	lit := &ast.ArrayLiteral{Token: p.currToken}
 	lit.Elements = p.parseExpressionList(token.RBRACKET)
@@ -258,22 +1035,26 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
 		if !p.expectPeek(token.COLON) {
-			return nil
+			return hash
 		}
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
-		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBRACE) {
+			p.peekError(token.COMMA, token.RBRACE)
+			return hash
 		}
 	}
-	if !p.expectPeek(token.RBRACE) {
-		return nil
-	}
+	p.nextToken()
 	return hash
 }
 
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	noLambda := p.noLambda
+	p.noLambda = false
+	defer func() { p.noLambda = noLambda }()
 	elements := []ast.Expression{}
 	if p.peekTokenIs(end) {
 		p.nextToken()
@@ -287,36 +1068,108 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 		p.nextToken()
 		elements = append(elements, p.parseExpression(LOWEST))
 	}
-	if !p.expectPeek(end) {
-		return nil
+	if !p.peekTokenIs(end) {
+		p.peekError(token.COMMA, end)
+		return elements
 	}
+	p.nextToken()
 	return elements
 }
-func (p *Parser) parseFunctionParameter() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+
+// parseFunctionParameters fills in the parameters of lit. Parameters with
+// default values must follow the required ones, and a rest parameter must
+// come last.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) {
+	lit.Parameters = []ast.Expression{}
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return
+	}
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENTIFIER) {
+				p.skipParameters()
+				return
+			}
+			lit.Rest = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+			if p.peekTokenIs(token.COMMA) {
+				p.currError("rest parameter ...%s must be the last parameter", lit.Rest.Value)
+				p.skipParameters()
+				return
+			}
+			break
+		}
+		if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+			p.nextToken()
+			pattern := p.parsePattern()
+			if p.recovering {
+				p.skipParameters()
+				return
+			}
+			if len(lit.Defaults) > 0 {
+				p.addError(&ParseError{
+					Pos:     pattern.Pos(),
+					Found:   p.currToken,
+					Message: fmt.Sprintf("parameter %s without a default value follows a parameter with one", pattern),
+				})
+				p.skipParameters()
+				return
+			}
+			lit.Parameters = append(lit.Parameters, pattern)
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+			continue
+		}
+		if !p.expectPeek(token.IDENTIFIER) {
+			p.skipParameters()
+			return
+		}
+		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		lit.Parameters = append(lit.Parameters, ident)
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if lit.Defaults == nil {
+				lit.Defaults = map[string]ast.Expression{}
+			}
+			lit.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 {
+			p.currError("parameter %s without a default value follows a parameter with one", ident.Value)
+			p.skipParameters()
+			return
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.peekError(token.COMMA, token.RPAREN)
+		p.skipParameters()
+		return
 	}
 	p.nextToken()
-	ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-	identifiers = append(identifiers, ident)
+}
 
-	for p.peekTokenIs(token.COMMA) {
+// skipParameters moves past the rest of a malformed parameter list so that
+// the function body can still be parsed.
+func (p *Parser) skipParameters() {
+	for !p.peekTokenIs(token.RPAREN) && !p.peekTokenIs(token.LBRACE) && !p.peekTokenIs(token.EOF) {
 		p.nextToken()
-		p.nextToken()
-		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
-
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
 	}
-	return identifiers
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currToken}
+	p.blockDepth += 1
+	defer func() { p.blockDepth -= 1 }()
 	block.Statements = []ast.Statement{}
 	p.nextToken()
 	for !p.currTokenIs(token.RBRACE) && !p.currTokenIs(token.EOF) {
@@ -324,21 +1177,61 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		if statement != nil {
 			block.Statements = append(block.Statements, statement)
 		}
+		if p.recovering {
+			p.synchronize()
+			if p.currTokenIs(token.RBRACE) {
+				return block
+			}
+		}
 		p.nextToken()
 	}
+	if p.currTokenIs(token.EOF) {
+		p.currError("expected } to close block opened at %s, got EOF instead", block.Token.Pos)
+	}
 	return block
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	integerValue, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
+	literal := strings.ReplaceAll(p.currToken.Literal, "_", "")
+	base := 10
+	if len(literal) > 1 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			literal = literal[2:]
+		}
+	}
+	integerValue, err := strconv.ParseInt(literal, base, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as intger", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		if errors.Is(err, strconv.ErrRange) {
+			p.currError("integer literal %s overflows int64", p.currToken.Literal)
+		} else if !p.currInvalid {
+			p.currError("could not parse %q as integer", p.currToken.Literal)
+		}
 		return nil
 	}
 	return &ast.IntegerLiteral{Token: p.currToken, Value: integerValue}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	floatValue, err := strconv.ParseFloat(strings.ReplaceAll(p.currToken.Literal, "_", ""), 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			p.currError("float literal %s is out of range", p.currToken.Literal)
+		} else if !p.currInvalid {
+			p.currError("could not parse %q as float", p.currToken.Literal)
+		}
+		return nil
+	}
+	return &ast.FloatLiteral{Token: p.currToken, Value: floatValue}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{Token: p.currToken, Operator: p.currToken.Literal}
 	p.nextToken()
@@ -349,11 +1242,55 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{Token: p.currToken, Left: left, Operator: p.currToken.Literal}
 	precedence := p.currPrecedence()
+	if p.currTokenIs(token.POWER) {
+		// ** is right-associative: 2 ** 3 ** 2 is 2 ** (3 ** 2).
+		precedence -= 1
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: p.currToken, Target: target}
+	switch target := target.(type) {
+	case *ast.Identifier:
+	case *ast.IndexExpression:
+		if target.Optional {
+			p.currError("cannot assign to %s", target)
+		}
+	default:
+		p.currError("cannot assign to %s", nodeDescription(target))
+	}
+	p.nextToken()
+	// Assignment is right-associative: a = b = c is a = (b = c).
+	expression.Value = p.parseExpression(ASSIGN - 1)
+	return expression
+}
+
+func nodeDescription(node ast.Node) string {
+	if node == nil {
+		return "missing expression"
+	}
+	return node.String()
+}
+
+// parsePipelineExpression rewrites x |> f(a) into the call f(x, a), and
+// x |> f into f(x). A right side that starts with a parenthesis, such as
+// x |> (f(a)), is called as a whole: f(a)(x).
+func (p *Parser) parsePipelineExpression(left ast.Expression) ast.Expression {
+	pipe := p.currToken
+	precedence := p.currPrecedence()
+	parenthesized := p.peekTokenIs(token.LPAREN)
+	p.nextToken()
+	right := p.parseExpression(precedence)
+	if call, ok := right.(*ast.CallExpression); ok && !parenthesized {
+		arguments := append([]ast.Expression{left}, call.Arguments...)
+		return &ast.CallExpression{Token: pipe, Function: call.Function, Arguments: arguments}
+	}
+	return &ast.CallExpression{Token: pipe, Function: right, Arguments: []ast.Expression{left}}
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expr := &ast.CallExpression{Token: p.currToken, Function: function}
 	expr.Arguments = p.parseExpressionList(token.RPAREN)
@@ -363,16 +1300,63 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
 	p.nextToken()
+	if p.currTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, nil)
+	}
 	exp.Index = p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RBRACKET) {
-		return nil
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
+	p.expectPeek(token.RBRACKET)
+	return exp
+}
+
+// parseSliceExpression parses the rest of left[start:end:step] from the
+// first colon on.
+func (p *Parser) parseSliceExpression(bracket token.Token, left ast.Expression, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: bracket, Left: left, Start: start}
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(LOWEST)
+		}
+	}
+	p.expectPeek(token.RBRACKET)
+	return exp
+}
+
+// parseDotExpression parses left.name, which is shorthand for left["name"].
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
+	if !p.expectPeek(token.IDENTIFIER) {
+		return exp
+	}
+	exp.Index = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+	return exp
+}
+
+func (p *Parser) parseOptionalIndexExpression(left ast.Expression) ast.Expression {
+	if !p.expectPeek(token.LBRACKET) {
+		return left
+	}
+	exp := p.parseIndexExpression(left)
+	switch exp := exp.(type) {
+	case *ast.IndexExpression:
+		exp.Optional = true
+	case *ast.SliceExpression:
+		exp.Optional = true
 	}
 	return exp
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.currError("no prefix parse function for %s found", t)
 }
 
 func (p *Parser) currTokenIs(token token.TokenType) bool {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/vshalt/arbok/ast"
 	"github.com/vshalt/arbok/lexer"
+	"github.com/vshalt/arbok/token"
 )
 
 func TestLetStatement(t *testing.T) {
@@ -53,6 +54,106 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringLiteralRoundTrip(t *testing.T) {
+	tests := []string{
+		`"line\nbreak \"quoted\" \\ tab\t"`,
+		"`raw \\n`",
+		`"héllo 世界"`,
+	}
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		literal := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+
+		reparser := New(lexer.New(program.String()))
+		reparsed := reparser.ParseProgram()
+		checkParserErrors(t, reparser)
+		again := reparsed.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+		if again.Value != literal.Value {
+			t.Errorf("round trip changed value. expected=%q, got=%q (printed %s)", literal.Value, again.Value, program.String())
+		}
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		parts    int
+	}{
+		{`"a ${b} c"`, `"a ${b} c"`, 3},
+		{`"${x + 1}${y}"`, `"${(x + 1)}${y}"`, 5},
+		{`"say \"${name}\"\n"`, `"say \"${name}\"\n"`, 3},
+		{`"outer ${ "inner ${x}" }"`, `"outer ${"inner ${x}"}"`, 3},
+		{`"\${literal}"`, `"\${literal}"`, 0},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+		expression := program.Statements[0].(*ast.ExpressionStatement).Expression
+		if tt.parts == 0 {
+			if _, ok := expression.(*ast.StringLiteral); !ok {
+				t.Errorf("expression is not ast.StringLiteral, got=%T", expression)
+			}
+			continue
+		}
+		str, ok := expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("expression is not ast.InterpolatedString, got=%T", expression)
+		}
+		if len(str.Parts) != tt.parts {
+			t.Errorf("wrong number of parts. expected=%d, got=%d", tt.parts, len(str.Parts))
+		}
+
+		reparser := New(lexer.New(program.String()))
+		reparsed := reparser.ParseProgram()
+		checkParserErrors(t, reparser)
+		if reparsed.String() != program.String() {
+			t.Errorf("round trip changed program. expected=%q, got=%q", program.String(), reparsed.String())
+		}
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${} b"`, "1:1: empty string interpolation"},
+		{`"a ${b`, "1:7: expected } to close string interpolation, got EOF instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected errors for %q", tt.input)
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestUnterminatedStringError(t *testing.T) {
+	input := `let s = "open;`
+	p := New(lexer.New(input))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error, got=%q", errors)
+	}
+	expected := "1:9: unterminated string literal\n\tlet s = \"open;\n\t        ^"
+	if errors[0].Format(input) != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Format(input))
+	}
+}
+
 func TestArrayLiteralExpression(t *testing.T) {
 	input := `[1, 2*2, 3 + 3];`
 	l := lexer.New(input)
@@ -91,6 +192,70 @@ func TestParsingIndexExpression(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = arr;", "let [a, b] = arr;"},
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;"},
+		{"let [...all] = arr;", "let [...all] = arr;"},
+		{"let [] = arr;", "let [] = arr;"},
+		{"let {name, age} = person;", "let {name, age} = person;"},
+		{`let {name: n, "home town": town} = person;`, `let {name: n, "home town": town} = person;`},
+		{"let [first, {id, tags: [tag]}] = rows;", "let [first, {id, tags: [tag]}] = rows;"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if statement.Name != nil || statement.Pattern == nil {
+			t.Errorf("destructuring let has wrong target. Name=%v, Pattern=%v", statement.Name, statement.Pattern)
+		}
+		if statement.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, statement.String())
+		}
+	}
+
+	p := New(lexer.New("let [a, ...rest] = xs;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	pattern, ok := program.Statements[0].(*ast.LetStatement).Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("pattern is not *ast.ArrayPattern")
+	}
+	if len(pattern.Elements) != 1 || !testIdentifier(t, pattern.Elements[0], "a") || !testIdentifier(t, pattern.Rest, "rest") {
+		t.Errorf("wrong array pattern. got=%s", pattern)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, 1] = xs;", "1:9: expected identifier or destructuring pattern, got INTEGER instead"},
+		{"let [...rest, a] = xs;", "1:9: rest element ...rest must be the last element"},
+		{"let [a b] = xs;", "1:8: expected next token to be one of ,, ], got IDENTIFIER instead"},
+		{`let {"key"} = h;`, "1:11: expected next token to be :, got } instead"},
+		{"let {1: a} = h;", "1:6: expected identifier or string as hash pattern key, got INTEGER instead"},
+		{"let [a] xs;", "1:9: expected next token to be =, got IDENTIFIER instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	input := `
 return 5;
@@ -168,6 +333,85 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xff;", 255},
+		{"0XFF;", 255},
+		{"0o755;", 493},
+		{"0b1010;", 10},
+		{"1_000_000;", 1000000},
+		{"0;", 0},
+		{"0x7fff_ffff_ffff_ffff;", 9223372036854775807},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		parser := New(l)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+		expressionStatement := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := expressionStatement.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("expression is not ast.IntegerLiteral, got=%T", expressionStatement.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value is not %d, got=%d", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestIntegerLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 9223372036854775808;", []string{"1:9: integer literal 9223372036854775808 overflows int64"}},
+		{"0x1_0000_0000_0000_0000", []string{"1:1: integer literal 0x1_0000_0000_0000_0000 overflows int64"}},
+		{"0b12", []string{"1:4: invalid digit '2' in binary literal"}},
+		{"let mode = 0755;", []string{"1:12: leading zeros are not allowed in decimal literal 0755, use the 0o prefix for octal"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Fatalf("wrong number of errors for %q. got=%q", tt.input, errors)
+		}
+		for i, expected := range tt.expected {
+			if errors[i].Error() != expected {
+				t.Errorf("wrong error. expected=%q, got=%q", expected, errors[i])
+			}
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"2.5e3;", 2500},
+		{"1e-2;", 0.01},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		parser := New(l)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+		expressionStatement := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := expressionStatement.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("expression is not ast.FloatLiteral, got=%T", expressionStatement.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value is not %g, got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestBooleanLiteralExpression(t *testing.T) {
 	input := `true;`
 	l := lexer.New(input)
@@ -201,6 +445,7 @@ func TestParsePrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"~15;", "~", 15},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
 	}
@@ -244,6 +489,12 @@ func TestParseInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"true && false;", true, "&&", false},
+		{"true || false;", true, "||", false},
 		{"true == true;", true, "==", true},
 		{"true != false;", true, "!=", false},
 		{"false == false;", false, "==", false},
@@ -360,6 +611,123 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (a) { x } else if (b) { y }", "if a x else if b y"},
+		{"if (a) { x } else if (b) { y } else { z }", "if a x else if b y else z"},
+		{"if (a < 1) { 1 } else if (a < 2) { 2 } else if (a < 3) { 3 } else { 4 }", "if (a < 1) 1 else if (a < 2) 2 else if (a < 3) 3 else 4"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not have 1 statement. got=%d", len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("if (a) { x } else if (b) { y } else { z }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	expr := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if len(expr.Alternative.Statements) != 1 {
+		t.Fatalf("Alternative is not 1 statement. got=%d", len(expr.Alternative.Statements))
+	}
+	statement, ok := expr.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Alternative.Statements[0] is not *ast.ExpressionStatement. got=%T", expr.Alternative.Statements[0])
+	}
+	nested, ok := statement.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("else branch is not *ast.IfExpression. got=%T", statement.Expression)
+	}
+	if !testIdentifier(t, nested.Condition, "b") {
+		return
+	}
+	if nested.Alternative == nil || nested.Alternative.String() != "z" {
+		t.Errorf("wrong nested alternative. got=%q", nested.Alternative)
+	}
+	if nested.Pos().Column != 19 {
+		t.Errorf("nested if has wrong position. got=%s", nested.Pos())
+	}
+
+	p = New(lexer.New("if (a) { x } else if b { y }"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0].Error() != "1:22: expected next token to be (, got IDENTIFIER instead" {
+		t.Errorf("wrong errors for malformed else if. got=%q", errors)
+	}
+}
+
+func TestMatchExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 => a, _ => b }", "match (x) { 1 => a, _ => b }"},
+		{`match (x) { -1 => a, 2.5 => b, "s" => c, true => d, }`, `match (x) { (-1) => a, 2.5 => b, "s" => c, true => d }`},
+		{"match (x) { INTEGER => 1, STRING(s) => s, n => n }", "match (x) { INTEGER => 1, STRING(s) => s, n => n }"},
+		{"match (xs) { [] => 0, [head, ...tail] => head + 1 }", "match (xs) { [] => 0, [head, ...tail] => (head + 1) }"},
+		{`match (msg) { {type: "ping"} => pong(), {type: "data", payload: [p]} => p }`, `match (msg) { {type: "ping"} => pong(), {type: "data", payload: [p]} => p }`},
+		{"match (n) { n if n > 0 => 1, n if (n < 0) => -1, _ => 0 }", "match (n) { n if (n > 0) => 1, n if (n < 0) => (-1), _ => 0 }"},
+		{"match (x) { }", "match (x) {  }"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("let r = match (x) { INTEGER(n) if n > 1 => n, _ => 0 };"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	match, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("value is not *ast.MatchExpression")
+	}
+	if !testIdentifier(t, match.Subject, "x") || len(match.Arms) != 2 {
+		t.Fatalf("wrong match expression. got=%s", match)
+	}
+	pattern, ok := match.Arms[0].Pattern.(*ast.TypePattern)
+	if !ok || pattern.Type != "INTEGER" || !testIdentifier(t, pattern.Pattern, "n") {
+		t.Errorf("wrong type pattern. got=%s", match.Arms[0].Pattern)
+	}
+	testInfixExpression(t, match.Arms[0].Guard, "n", ">", 1)
+	if match.Arms[1].Guard != nil {
+		t.Errorf("second arm has a guard. got=%s", match.Arms[1].Guard)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 a }", "1:15: expected next token to be =>, got IDENTIFIER instead"},
+		{"match (x) { 1 => a b => c }", "1:20: expected next token to be one of ,, }, got IDENTIFIER instead"},
+		{"match (x) { (1) => a }", "1:13: expected match pattern, got ( instead"},
+		{"match (x) { - a => a }", "1:15: expected next token to be one of INTEGER, FLOAT, got IDENTIFIER instead"},
+		{"match x { _ => 1 }", "1:7: expected next token to be (, got IDENTIFIER instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `func(x, y) {x + y;}`
 	lexer := lexer.New(input)
@@ -396,6 +764,45 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStatement.Expression, "x", "+", "y")
 }
 
+func TestFunctionStatementParsing(t *testing.T) {
+	input := `func add(x, y) { x + y; }`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	statement, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, statement.Name, "add") {
+		return
+	}
+	if statement.Function.Name != "add" {
+		t.Errorf("function literal name is not %q. got=%q", "add", statement.Function.Name)
+	}
+	if len(statement.Function.Parameters) != 2 {
+		t.Fatalf("function has wrong parameters. got=%d", len(statement.Function.Parameters))
+	}
+	testLiteralExpression(t, statement.Function.Parameters[0], "x")
+	testLiteralExpression(t, statement.Function.Parameters[1], "y")
+	if statement.String() != "func add(x, y) (x + y)" {
+		t.Errorf("statement.String() wrong. got=%q", statement.String())
+	}
+
+	p = New(lexer.New(`let f = func(x) { x }; func(x) { x }(1);`))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	let := program.Statements[0].(*ast.LetStatement)
+	if fn := let.Value.(*ast.FunctionLiteral); fn.Name != "f" {
+		t.Errorf("let-bound function literal has wrong name. got=%q", fn.Name)
+	}
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement); !ok {
+		t.Errorf("anonymous function is not an expression statement. got=%T", program.Statements[1])
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 
 	tests := []struct {
@@ -425,6 +832,296 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults map[string]string
+		expectedRest     string
+		expected         string
+	}{
+		{"func(a, b = 10) {}", []string{"a", "b"}, map[string]string{"b": "10"}, "", "func(a, b = 10) "},
+		{"func(a = 1, b = a * 2) {}", []string{"a", "b"}, map[string]string{"a": "1", "b": "(a * 2)"}, "", "func(a = 1, b = (a * 2)) "},
+		{"func(first, ...rest) {}", []string{"first"}, map[string]string{}, "rest", "func(first, ...rest) "},
+		{"func(...args) {}", []string{}, map[string]string{}, "args", "func(...args) "},
+		{"func(a, b = 2, ...c) { c }", []string{"a", "b"}, map[string]string{"b": "2"}, "c", "func(a, b = 2, ...c) c"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("wrong number of parameters for %q. got=%d", tt.input, len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Errorf("wrong number of defaults for %q. got=%d", tt.input, len(function.Defaults))
+		}
+		for name, expected := range tt.expectedDefaults {
+			if value, ok := function.Defaults[name]; !ok || value.String() != expected {
+				t.Errorf("wrong default for %s. expected=%q, got=%v", name, expected, value)
+			}
+		}
+		if tt.expectedRest == "" && function.Rest != nil {
+			t.Errorf("unexpected rest parameter %q", function.Rest)
+		}
+		if tt.expectedRest != "" && !testIdentifier(t, function.Rest, tt.expectedRest) {
+			return
+		}
+		if function.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, function.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"func(a = 1, b) {}", "1:13: parameter b without a default value follows a parameter with one"},
+		{"func(...a, b) {}", "1:9: rest parameter ...a must be the last parameter"},
+		{"func(...) {}", "1:9: expected next token to be IDENTIFIER, got ) instead"},
+		{"func(...a = 1) {}", "1:11: expected next token to be one of ,, ), got = instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestDestructuringParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"func([a, b]) { a }", "func([a, b]) a"},
+		{"func({name, age}, extra = 1) { name }", "func({name, age}, extra = 1) name"},
+		{"func(x, [head, ...tail], ...rest) { tail }", "func(x, [head, ...tail], ...rest) tail"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("func(a = 1, [b]) {}"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0].Error() != "1:13: parameter [b] without a default value follows a parameter with one" {
+		t.Errorf("wrong errors for pattern after default. got=%q", errors)
+	}
+}
+
+func TestModuleParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/strings" as s`, `import "lib/strings" as s;`},
+		{`import "a" as a; import "b" as b;`, `import "a" as a;import "b" as b;`},
+		{`export let x = 1;`, `export let x = 1;`},
+		{`export let [a, ...b] = c;`, `export let [a, ...b] = c;`},
+		{`export func add(a, b) { a + b }`, `export func add(a, b) (a + b)`},
+		{`s.upper`, `(s["upper"])`},
+		{`s.upper("x")`, `(s["upper"])("x")`},
+		{`a.b.c[0]`, `(((a["b"])["c"])[0])`},
+		{`-a.b`, `(-(a["b"]))`},
+		{`h.x = 1`, `((h["x"]) = 1)`},
+		{`let import = 1; import + 1`, `let import = 1;(import + 1)`},
+		{`export(x); export = 2; export`, `export(x)(export = 2)export`},
+		{`let as = 1; import "a" as as`, `let as = 1;import "a" as as;`},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New(`import "lib/strings" as s; export func f() {}`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if imp.Path.Value != "lib/strings" || imp.Name.Value != "s" {
+		t.Errorf("wrong import. path=%q, name=%q", imp.Path.Value, imp.Name.Value)
+	}
+	export, ok := program.Statements[1].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ExportStatement. got=%T", program.Statements[1])
+	}
+	if _, ok := export.Statement.(*ast.FunctionStatement); !ok {
+		t.Errorf("exported statement is not *ast.FunctionStatement. got=%T", export.Statement)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`import strings as s`, "1:8: expected next token to be STRING, got IDENTIFIER instead"},
+		{`import "strings" s`, "1:18: expected next token to be as, got IDENTIFIER instead"},
+		{`import "strings" as "s"`, "1:21: expected next token to be IDENTIFIER, got STRING instead"},
+		{`export 1`, "1:8: expected next token to be one of LET, FUNCTION, got INTEGER instead"},
+		{`export func (a) { a }`, "1:13: expected next token to be IDENTIFIER, got ( instead"},
+		{`func f() { export let x = 1; }`, "1:12: export is only allowed at the top level"},
+		{`a.1`, "1:3: expected next token to be IDENTIFIER, got INTEGER instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("expression is not *ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements does not contain 1 statement. got=%d", len(macro.Body.Statements))
+	}
+	body, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body statement is not *ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+	testInfixExpression(t, body.Expression, "x", "+", "y")
+	if macro.String() != "macro(x, y) (x + y)" {
+		t.Errorf("wrong String(). got=%q", macro.String())
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"macro([a]) { a }", "1:7: macro parameter must be an identifier, got [a]"},
+		{"macro(a = 1) { a }", "1:1: macro parameters cannot have default values or be rest parameters"},
+		{"macro(...a) { a }", "1:1: macro parameters cannot have default values or be rest parameters"},
+		{"while (true) { macro() { break; } }", "1:26: break outside of loop"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestArrowFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x => x * 2", "func(x) (x * 2)"},
+		{"(x, y) => x + y", "func(x, y) (x + y)"},
+		{"() => 42", "func() 42"},
+		{"(x) => x", "func(x) x"},
+		{"(a, b = 10, ...rest) => a", "func(a, b = 10, ...rest) a"},
+		{"([a, b]) => a", "func([a, b]) a"},
+		{`({"x": x, y: [z, w]}, v) => x`, `func({"x": x, y: [z, w]}, v) x`},
+		{"([[a], {b: c}], d = 1) => a", "func([[a], {b: c}], d = 1) a"},
+		{"x => { let y = x; y * 2 }", "func(x) let y = x;(y * 2)"},
+		{"x => y => x + y", "func(x) func(y) (x + y)"},
+		{"map(xs, x => x * 2)", "map(xs, func(x) (x * 2))"},
+		{"reduce(xs, (acc, x) => acc + x, 0)", "reduce(xs, func(acc, x) (acc + x), 0)"},
+		{"(x => x)(1)", "func(x) x(1)"},
+		{"(1 + 2) * 3", "((1 + 2) * 3)"},
+		{"(a = 1)", "(a = 1)"},
+		{"match (n) { x if x > 0 => x, _ => 0 }", "match (n) { x if (x > 0) => x, _ => 0 }"},
+		{"match (n) { x if (ok) => x, _ => 0 }", "match (n) { x if ok => x, _ => 0 }"},
+		{"match (n) { x if any(xs, y => y > x) => x }", "match (n) { x if any(xs, func(y) (y > x)) => x }"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d (%q)", len(program.Statements), program.String())
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("let double = (x) => x * 2;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	function, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("value is not *ast.FunctionLiteral")
+	}
+	if function.Name != "double" || len(function.Parameters) != 1 || !testIdentifier(t, function.Parameters[0], "x") {
+		t.Errorf("wrong arrow function. got=%s (name %q)", function, function.Name)
+	}
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("arrow function body does not have 1 statement. got=%d", len(function.Body.Statements))
+	}
+	body, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok || !testInfixExpression(t, body.Expression, "x", "*", 2) {
+		t.Errorf("wrong arrow function body. got=%s", function.Body)
+	}
+	if pos := function.Pos(); pos.Line != 1 || pos.Column != 14 {
+		t.Errorf("arrow function has wrong position. got=%s", pos)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"(a, b)", "1:7: expected next token to be =>, got EOF instead"},
+		{"()", "1:3: expected next token to be =>, got EOF instead"},
+		{"(a, 1) => a", "1:5: invalid parameter 1"},
+		{"(a = 1, b) => a", "1:9: parameter b without a default value follows a parameter with one"},
+		{"(a[0] = 1) => a", "1:7: invalid parameter ((a[0]) = 1)"},
+		{"([a, 1]) => a", "1:2: invalid parameter [a, 1]"},
+		{"({1: a}) => a", "1:2: invalid parameter {1:a}"},
+		{"(a = 1, [b]) => a", "1:9: parameter [b] without a default value follows a parameter with one"},
+		{"while (x) { let f = () => { break; }; }", "1:29: break outside of loop"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5)`
 	lexer := lexer.New(input)
@@ -487,6 +1184,34 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a+b+c*d/f+g)", "add((((a + b) + ((c * d) / f)) + g))"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c < d", "((a == b) && (c < d))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b == 0", "((a & b) == 0)"},
+		{"1 << 2 + 3", "(1 << (2 + 3))"},
+		{"a & b << 1", "(a & (b << 1))"},
+		{"~a & b", "((~a) & b)"},
+		{`a?.["b"]`, `(a?.["b"])`},
+		{`config?.["db"]?.["port"] ?? 5432`, `(((config?.["db"])?.["port"]) ?? 5432)`},
+		{"a?.[b][c]", "((a?.[b])[c])"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b || c", "(a ?? (b || c))"},
+		{"x = a ?? b", "(x = (a ?? b))"},
+		{"xs |> filter(isEven) |> map(square)", "map(filter(xs, isEven), square)"},
+		{"xs |> len", "len(xs)"},
+		{"a + b |> f(c * d)", "f((a + b), (c * d))"},
+		{"a ?? b |> f()", "f((a ?? b))"},
+		{"y = xs |> f()", "(y = f(xs))"},
+		{"x |> (v => v * 2)", "func(v) (v * 2)(x)"},
+		{"x |> (mk())", "mk()(x)"},
+		{"x |> (mk(a))(b)", "mk(a)(b)(x)"},
+		{"a | b |> f", "f((a | b))"},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -526,7 +1251,7 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral, got=%T", key)
 		}
-		expectedValue := expected[literal.String()]
+		expectedValue := expected[literal.Value]
 		testIntegerLiteral(t, value, expectedValue)
 	}
 }
@@ -580,15 +1305,373 @@ func TestParsingHashLiteralWithExpression(t *testing.T) {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
 			continue
 		}
-		testFunc, ok := tests[literal.String()]
+		testFunc, ok := tests[literal.Value]
 		if !ok {
-			t.Errorf("No test function for key %q found", literal.String())
+			t.Errorf("No test function for key %q found", literal.Value)
 			continue
 		}
 		testFunc(value)
 	}
 }
 
+func TestParserErrorPositions(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+	l := lexer.NewWithFilename("main.arb", input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+	expected := "main.arb:2:5: expected next token to be IDENTIFIER, got = instead\n\tlet = 10;\n\t    ^"
+	if errors[0].Format(input) != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0].Format(input))
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5;", "(x = 5)"},
+		{"x = y = 1 + 2;", "(x = (y = (1 + 2)))"},
+		{"arr[0] = 1;", "((arr[0]) = 1)"},
+		{`h["k"] = v || w;`, `((h["k"]) = (v || w))`},
+		{"let a = b = 3;", "let a = (b = 3);"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("1 + 2 = 3;"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0].Error() != "1:7: cannot assign to (1 + 2)" {
+		t.Errorf("wrong errors for invalid assignment target, got=%q", errors)
+	}
+}
+
+func TestLoopStatementParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x = x + 1; }", "while (x < 10) (x = (x + 1))"},
+		{"for (let i = 0; i < 3; i = i + 1) { puts(i) }", "for (let i = 0; (i < 3); (i = (i + 1))) puts(i)"},
+		{"for (i = 0; i < 3; i = i + 1) { }", "for ((i = 0); (i < 3); (i = (i + 1))) "},
+		{"for (;;) { break; }", "for (; ; ) break;"},
+		{"while (true) { if (x) { continue } break }", "while true if x continue;break;"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("for (let i = 0; i < 3; i = i + 1) { i }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	statement, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := statement.Init.(*ast.LetStatement); !ok {
+		t.Errorf("statement.Init is not *ast.LetStatement. got=%T", statement.Init)
+	}
+	if !testInfixExpression(t, statement.Condition, "i", "<", 3) {
+		return
+	}
+	if _, ok := statement.Post.(*ast.AssignExpression); !ok {
+		t.Errorf("statement.Post is not *ast.AssignExpression. got=%T", statement.Post)
+	}
+}
+
+func TestForInStatementParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKey   string
+		expectedValue string
+		expected      string
+	}{
+		{"for (x in xs) { x }", "", "x", "for (x in xs) x"},
+		{"for (k, v in h) { k + v }", "k", "v", "for (k, v in h) (k + v)"},
+		{"for (i in range(0, 10)) { if (i > 2) { break } }", "", "i", "for (i in range(0, 10)) if (i > 2) break;"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		statement, ok := program.Statements[0].(*ast.ForInStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ForInStatement. got=%T", program.Statements[0])
+		}
+		if tt.expectedKey == "" && statement.Key != nil {
+			t.Errorf("statement.Key is not nil. got=%q", statement.Key)
+		}
+		if tt.expectedKey != "" && !testIdentifier(t, statement.Key, tt.expectedKey) {
+			return
+		}
+		if !testIdentifier(t, statement.Value, tt.expectedValue) {
+			return
+		}
+		if statement.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, statement.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (k, in h) {}", "1:9: expected next token to be IDENTIFIER, got IN instead"},
+		{"for (k, v h) {}", "1:11: expected next token to be IN, got IDENTIFIER instead"},
+		{"for (x in xs { x }", "1:14: expected next token to be ), got { instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestLoopControlErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of loop"},
+		{"if (x) { continue }", "1:10: continue outside of loop"},
+		{"while (x) { let f = func() { break; }; }", "1:30: break outside of loop"},
+		{"for (let i = 0 i < 3; i) {}", "1:16: expected next token to be ;, got IDENTIFIER instead"},
+		{"for (;; i {}", "1:11: expected next token to be ), got { instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestSliceExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[1:2:3]", "(a[1:2:3])"},
+		{"a[::]", "(a[:])"},
+		{"a[-1:i + 1:-1]", "(a[(-1):(i + 1):(-1)])"},
+		{"a?.[1:]", "(a?.[1:])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("a[1:2:3]"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	slice, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("expression is not *ast.SliceExpression")
+	}
+	testIdentifier(t, slice.Left, "a")
+	testIntegerLiteral(t, slice.Start, 1)
+	testIntegerLiteral(t, slice.End, 2)
+	testIntegerLiteral(t, slice.Step, 3)
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2", "1:6: expected next token to be ], got EOF instead"},
+		{"a[1:2:3:4]", "1:8: expected next token to be ], got : instead"},
+		{"a[1:2] = 3", "1:8: cannot assign to (a[1:2])"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestOptionalIndexErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a?.b", "1:4: expected next token to be [, got IDENTIFIER instead"},
+		{"a?.[1] = 2", "1:8: cannot assign to (a?.[1])"},
+		{"a ? b", "1:3: no prefix parse function for ILLEGAL found"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestPartialProgramString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let", "let  = ;"},
+		{"let x =", "let x = ;"},
+		{"func f(", "func f() "},
+		{"if (x) {", "if x "},
+		{"while (x)", "while x "},
+		{"for (k, v in", "for (k, v in ) "},
+		{`import "a" as`, `import "a" as ;`},
+		{"export func", "export "},
+		{"a[1:", "(a[1:])"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected errors for %q", tt.input)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("wrong String() for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements int
+	}{
+		{
+			"let = 1; let y 2; let z = 3; z",
+			[]string{
+				"1:5: expected next token to be IDENTIFIER, got = instead",
+				"1:16: expected next token to be =, got INTEGER instead",
+			},
+			4,
+		},
+		{
+			"add(1, 2; let x = 5; x",
+			[]string{"1:9: expected next token to be one of ,, ), got ; instead"},
+			3,
+		},
+		{
+			"if (x { x } let a = 1;",
+			[]string{"1:7: expected next token to be ), got { instead"},
+			2,
+		},
+		{
+			"let f = func(x) {\n  let = 1;\n  let y = ;\n  x\n};\nlet ok = 1;",
+			[]string{
+				"2:7: expected next token to be IDENTIFIER, got = instead",
+				"3:11: no prefix parse function for ; found",
+			},
+			2,
+		},
+		{
+			"let x = [1, 2 3]; let y = {1: 2 3: 4}; y",
+			[]string{
+				"1:15: expected next token to be one of ,, ], got INTEGER instead",
+				"1:33: expected next token to be one of ,, }, got INTEGER instead",
+			},
+			3,
+		},
+		{
+			"let f = func(x) { x",
+			[]string{"1:20: expected } to close block opened at 1:17, got EOF instead"},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. expected=%d, got=%q", tt.input, len(tt.expectedErrors), errors)
+			continue
+		}
+		for i, expected := range tt.expectedErrors {
+			if errors[i].Error() != expected {
+				t.Errorf("wrong error. expected=%q, got=%q", expected, errors[i].Error())
+			}
+		}
+		if len(program.Statements) != tt.expectedStatements {
+			t.Errorf("wrong number of statements for %q. expected=%d, got=%d (%q)", tt.input, tt.expectedStatements, len(program.Statements), program.String())
+		}
+	}
+}
+
+func TestParseErrorDetails(t *testing.T) {
+	p := New(lexer.New("let x = [1 2];"))
+	program := p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got=%q", errors)
+	}
+	err := errors[0]
+	if len(err.Expected) != 2 || err.Expected[0] != token.COMMA || err.Expected[1] != token.RBRACKET {
+		t.Errorf("wrong expected token set, got=%v", err.Expected)
+	}
+	if err.Found.Type != token.INTEGER || err.Found.Literal != "2" {
+		t.Errorf("wrong found token, got=%+v", err.Found)
+	}
+	if err.Pos.Line != 1 || err.Pos.Column != 12 {
+		t.Errorf("wrong position, got=%s", err.Pos)
+	}
+
+	let, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.LetStatement, got=%T", program.Statements[0])
+	}
+	array, ok := let.Value.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("partial value is not *ast.ArrayLiteral, got=%T", let.Value)
+	}
+	if len(array.Elements) != 1 || !testIntegerLiteral(t, array.Elements[0], 1) {
+		t.Errorf("partial array has wrong elements, got=%s", array.String())
+	}
+}
+
 func testLetStatement(t *testing.T, statement ast.Statement, expectedIdentifier string) bool {
 	if statement.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral is not 'let', got=%q", statement.TokenLiteral())
@@ -701,7 +1784,7 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.Errorf("parser has %d errors", len(errors))
 	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+		t.Errorf("parser error: %q", msg.Error())
 	}
 	t.FailNow()
 }
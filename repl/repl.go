@@ -4,19 +4,31 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 
 	"github.com/vshalt/arbok/evaluator"
 	"github.com/vshalt/arbok/lexer"
 	"github.com/vshalt/arbok/object"
 	"github.com/vshalt/arbok/parser"
+	"github.com/vshalt/arbok/token"
 )
 
 const PROMPT = `Hello, welcome to arbok!
 >> `
 
-func Start(in io.Reader, out io.Writer) {
+// Start runs the REPL, in strict mode if strict is set.
+func Start(in io.Reader, out io.Writer, strict bool) {
+	evaluator.ResetModules()
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	env.SetStrict(strict)
+	macroEnv := object.NewEnvironment()
+	macroEnv.SetStrict(strict)
+	// history holds every line entered so far. Each entry is lexed as the
+	// next line of one long source, so errors raised in code from an earlier
+	// entry point into that entry.
+	history := []string{}
 	fmt.Printf(PROMPT)
 	for {
 		scanned := scanner.Scan()
@@ -27,16 +39,30 @@ func Start(in io.Reader, out io.Writer) {
 		if line == "exit" {
 			break
 		}
+		history = append(history, line)
+		source := strings.Join(history, "\n")
 		l := lexer.New(line)
+		l.StartAtLine(len(history))
 		p := parser.New(l)
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, source, p.Errors())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			printEvalError(out, source, err)
+			continue
+		}
+
+		evaluated := evaluator.Eval(expanded, env)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			printEvalError(out, source, errObj)
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n>> ")
@@ -44,9 +70,60 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// RunFile runs the script in filename, in strict mode if strict is set,
+// writing any errors to out, and reports whether it ran successfully.
+func RunFile(filename string, out io.Writer, strict bool) bool {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(out, "ERROR: %s\n", err)
+		return false
+	}
+	p := parser.New(lexer.NewWithFilename(filename, string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, string(source), p.Errors())
+		return false
+	}
+
+	evaluator.ResetModules()
+	macroEnv := object.NewEnvironment()
+	macroEnv.SetStrict(strict)
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, errObj := evaluator.ExpandMacros(program, macroEnv)
+	if errObj == nil {
+		env := object.NewEnvironment()
+		env.SetStrict(strict)
+		evaluated := evaluator.EvalMain(filename, expanded, env)
+		errObj, _ = evaluated.(*object.Error)
+	}
+	if errObj != nil {
+		io.WriteString(out, formatEvalError(filename, string(source), errObj)+"\n")
+		return false
+	}
+	return true
+}
+
+func printEvalError(out io.Writer, source string, err *object.Error) {
+	io.WriteString(out, formatEvalError("", source, err))
+	io.WriteString(out, "\n>> ")
+}
+
+// formatEvalError renders err against source, the contents of filename,
+// or against the imported module the error was raised in.
+func formatEvalError(filename string, source string, err *object.Error) string {
+	if err.Pos.Filename != filename {
+		module, readErr := os.ReadFile(err.Pos.Filename)
+		if readErr != nil {
+			return "ERROR: " + err.Pos.String() + ": " + err.Message
+		}
+		source = string(module)
+	}
+	return "ERROR: " + token.FormatError(source, err.Pos, err.Message)
+}
+
+func printParserErrors(out io.Writer, source string, errors []*parser.ParseError) {
 	io.WriteString(out, "parser ran into errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, err := range errors {
+		io.WriteString(out, err.Format(source)+"\n")
 	}
 }
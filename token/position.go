@@ -0,0 +1,51 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return p.Filename
+	}
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// FormatError renders msg prefixed with pos and, when pos falls inside
+// source, followed by the offending line with a caret under the column.
+func FormatError(source string, pos Position, msg string) string {
+	if !pos.IsValid() {
+		return msg
+	}
+	out := fmt.Sprintf("%s: %s", pos, msg)
+	lines := strings.Split(source, "\n")
+	if pos.Line > len(lines) {
+		return out
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+	var caret strings.Builder
+	for i, ch := range []rune(line) {
+		if i >= pos.Column-1 {
+			break
+		}
+		if ch == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+	return fmt.Sprintf("%s\n\t%s\n\t%s", out, line, caret.String())
+}
@@ -5,6 +5,17 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+
+	// Leading and Trailing hold the comments around the token when the
+	// lexer is asked to keep them; they are nil otherwise.
+	Leading  []Comment
+	Trailing []Comment
+}
+
+type Comment struct {
+	Text string
+	Pos  Position
 }
 
 const (
@@ -14,6 +25,11 @@ const (
 	MINUS        = "-"
 	ASTERISK     = "*"
 	SLASH        = "/"
+	PERCENT      = "%"
+	AMPERSAND    = "&"
+	PIPE         = "|"
+	CARET        = "^"
+	TILDE        = "~"
 	GREATER_THAN = ">"
 	LESS_THAN    = "<"
 	COMMA        = ","
@@ -26,32 +42,64 @@ const (
 	LBRACKET     = "["
 	RBRACKET     = "]"
 
-	EQUAL     = "=="
-	NOT_EQUAL = "!="
+	EQUAL         = "=="
+	NOT_EQUAL     = "!="
+	LESS_EQUAL    = "<="
+	GREATER_EQUAL = ">="
+	POWER         = "**"
+	AND           = "&&"
+	OR            = "||"
+	SHIFT_LEFT    = "<<"
+	SHIFT_RIGHT   = ">>"
+	ELLIPSIS      = "..."
+	ARROW         = "=>"
+	QUESTION_DOT  = "?."
+	NULLISH       = "??"
+	PIPELINE      = "|>"
+	DOT           = "."
 
 	LET        = "LET"
 	FUNCTION   = "FUNCTION"
 	IF         = "IF"
 	ELSE       = "ELSE"
 	RETURN     = "RETURN"
+	WHILE      = "WHILE"
+	FOR        = "FOR"
+	BREAK      = "BREAK"
+	CONTINUE   = "CONTINUE"
+	IN         = "IN"
+	MATCH      = "MATCH"
+	MACRO      = "MACRO"
 	TRUE       = "TRUE"
 	FALSE      = "FALSE"
 	INTEGER    = "INTEGER"
+	FLOAT      = "FLOAT"
 	IDENTIFIER = "IDENTIFIER"
 	STRING     = "STRING"
 
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
+
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 )
 
 var keywords = map[string]TokenType{
-	"let":    LET,
-	"func":   FUNCTION,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
+	"let":      LET,
+	"func":     FUNCTION,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"in":       IN,
+	"match":    MATCH,
+	"macro":    MACRO,
+	"true":     TRUE,
+	"false":    FALSE,
 }
 
 func LookupIdentifier(identifier string) TokenType {